@@ -6,9 +6,13 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/markdumay/restic-unattended/lib"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -16,8 +20,17 @@ import (
 // Variables
 //======================================================================================================================
 
-// BackupPath specifies the source path to backup
-var BackupPath string
+// backupFlags matches the flags relayed to the restic backup command, such as tags and exclude patterns
+const backupFlags = "^(files-from|tag|exclude|exclude-file|iexclude|exclude-caches|exclude-if-present|one-file-system)$"
+
+// BackupPaths specifies the source paths to backup
+var BackupPaths []string
+
+// StdinCommand specifies a shell command of which the output is backed up instead of BackupPaths
+var StdinCommand string
+
+// StdinFilename specifies the file name of the output of StdinCommand in the snapshot
+var StdinFilename string
 
 // Host to use in backups (defaults to $HOSTNAME)
 var Host string
@@ -25,6 +38,12 @@ var Host string
 // InitRepository initializes the repository if it does not exist yet
 var InitRepository bool
 
+// BackupHooks defines the commands to run before and after a backup, as defined in the config file.
+var BackupHooks *lib.Hooks
+
+// BackupDocker defines the Docker containers to stop during a backup, as defined in the config file.
+var BackupDocker *lib.DockerOptions
+
 // backupCmd represents the backup command
 var backupCmd = &cobra.Command{
 	Use:   "backup",
@@ -33,20 +52,112 @@ var backupCmd = &cobra.Command{
 Creates a backup of the specified path and its subdirectories and stores it in
 a repository. The repository can be stored locally, or on a remote server.
 Backup connects to a previously initialized repository only, unless the flag
---init is added.`,
+--init is added.
+
+Repository profiles defined in the config file can be targeted with the flag
+--profile, which can be repeated. The value 'all' targets all profiles. For
+example, the following configuration defines a local NAS and an offsite
+Backblaze B2 repository, each with their own credentials:
+
+repositories:
+  - name: nas
+    repository: rest:http://nas:8000/
+    password_file: /run/secrets/NAS_PASSWORD
+  - name: b2
+    repository_file: /run/secrets/B2_REPOSITORY
+    password_file: /run/secrets/B2_PASSWORD
+    env:
+      B2_ACCOUNT_ID_FILE: /run/secrets/B2_ACCOUNT_ID
+      B2_ACCOUNT_KEY_FILE: /run/secrets/B2_ACCOUNT_KEY
+
+Hooks defined in the config file run before and after the backup, for example
+to create a database dump in the backup path and to remove it afterwards. Each
+hook has an optional timeout and failure policy: abort (default), warn, or
+ignore. The hooks receive the backup path as RESTIC_BACKUP_PATH, post-backup
+hooks receive the outcome as RESTIC_BACKUP_RESULT (success or failure) too:
+
+hooks:
+  pre:
+    - command: /bin/sh
+      args: ['-c', 'pg_dump -h db mydb > "$RESTIC_BACKUP_PATH/mydb.sql"']
+      timeout: 10m
+      on_error: abort
+  post:
+    - command: /bin/sh
+      args: ['-c', 'rm -f "$RESTIC_BACKUP_PATH/mydb.sql"']
+      on_error: warn
+
+Containers can be stopped or paused during the backup to ensure their data is
+consistent. The containers are identified by a label and are restarted after
+the backup, even if the backup fails. This requires access to the Docker socket
+(e.g. by mounting /var/run/docker.sock). For example:
+
+docker:
+  label: restic-unattended.stop=true
+  action: stop     # or pause
+  timeout: 30s     # grace period before a container is killed
+
+Multiple paths can be backed up by repeating the flag --path, or by reading the
+paths from a file with the flag --files-from (one path per line). The paths can
+be set in the config file as a list too ('backup_path'), or in the environment
+as RESTIC_BACKUP_PATH separated by ':' (e.g. /data/docs:/data/photos). Each
+snapshot can be tagged with the repeatable flag --tag, which allows to filter
+snapshots and to retain them with 'forget --keep-tag'. Tags can be set in the
+config file ('tag') and environment (RESTIC_TAG, separated by spaces) too.
+
+Files and directories can be excluded with the flags --exclude, --iexclude
+(case insensitive), --exclude-file, --exclude-if-present, --exclude-caches, and
+--one-file-system, which are relayed to restic. The flags can be set in the
+config file and environment too, using underscores instead of dashes (e.g.
+'exclude_caches: true' or RESTIC_EXCLUDE_CACHES=true). Multiple patterns are
+separated by spaces in the environment, for example:
+
+exclude: ['*.tmp', '/data/cache']
+exclude_caches: true
+one_file_system: true
+
+The output of a shell command can be backed up instead of a path with the flag
+--stdin-command. The output is streamed into the repository directly, so it
+does not need to be written to disk first. Restic stores the output as a single
+file, named by the flag --stdin-filename (defaults to 'stdin').
+
+Examples:
+restic-unattended backup --path /data --profile all
+Creates a backup of /data in both the nas and b2 repository.
+
+restic-unattended backup --path /data/docs --path /data/photos --tag personal
+Creates a single snapshot of both paths, tagged as 'personal'.
+
+restic-unattended backup --path /data --exclude '*.tmp' --exclude-caches
+Creates a backup of /data, excluding temporary files and cache directories.
+
+restic-unattended backup --stdin-command 'mysqldump -h db --all-databases' \
+  --stdin-filename all-databases.sql
+Creates a backup of a database dump without storing the dump on disk.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		if BackupPath == "" {
-			return errors.New("No backup path provided")
+		if err := initBackupSource(cmd.Flags()); err != nil {
+			return err
 		}
-		return nil
+		if err := initHooks(); err != nil {
+			return err
+		}
+		if err := initDocker(); err != nil {
+			return err
+		}
+		return initProfiles(cmd)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		f := func() error {
-			r, err := lib.NewResticManager()
+			flags, err := lib.ParseArgs(cmd.Flags(), backupFlags)
 			if err != nil {
 				return err
 			}
-			return r.Backup(BackupPath, InitRepository, Host)
+			return forEachRepository(func(r *lib.ResticManager) error {
+				opts := lib.BackupOptions{Paths: BackupPaths, Flags: flags, Init: InitRepository, Host: Host,
+					Hooks: BackupHooks, Docker: BackupDocker, Stdin: stdinSource()}
+				_, err := r.BackupWithOptions(opts)
+				return err
+			})
 		}
 		lib.HandleCmd(f, "Error running backup", false)
 	},
@@ -56,29 +167,133 @@ Backup connects to a previously initialized repository only, unless the flag
 // Private Functions
 //======================================================================================================================
 
-// TODO: verify if Host and BackupPath are properly initialized; consider to move to PreRunE
+// TODO: verify if Host is properly initialized; consider to move to PreRunE
 func addBackupOptions(c *cobra.Command) error {
 	f := c.Flags()
 	f.BoolVar(&InitRepository, "init", false, "initialize the repository if it does not exist yet")
-	f.StringVarP(&BackupPath, "path", "p", "", "local path to backup")
-	// bind backup path to environment variables
-	if err := viper.BindPFlag("backup_path", f.Lookup("path")); err != nil {
-		return fmt.Errorf("Could not bind backup_path flag")
-	}
-	BackupPath = viper.GetString("backup_path")
+	f.StringArrayVarP(&BackupPaths, "path", "p", []string{}, "local path to backup (can be specified multiple times)")
 	f.StringVarP(&Host, "host", "H", "", "hostname to use in backups (defaults to $HOSTNAME)")
 	// bind host to environment variables
 	if err := viper.BindPFlag("host", f.Lookup("host")); err != nil {
 		return fmt.Errorf("Could not bind host flag")
 	}
 	Host = viper.GetString("host")
+	f.StringVar(&StdinCommand, "stdin-command", "", "shell command of which the output is backed up instead of a path")
+	// bind stdin command to environment variables
+	if err := viper.BindPFlag("stdin_command", f.Lookup("stdin-command")); err != nil {
+		return fmt.Errorf("Could not bind stdin_command flag")
+	}
+	StdinCommand = viper.GetString("stdin_command")
+	f.StringVar(&StdinFilename, "stdin-filename", "", "file name of the stdin command output (defaults to 'stdin')")
+	// bind stdin filename to environment variables
+	if err := viper.BindPFlag("stdin_filename", f.Lookup("stdin-filename")); err != nil {
+		return fmt.Errorf("Could not bind stdin_filename flag")
+	}
+	StdinFilename = viper.GetString("stdin_filename")
+	addExcludeOptions(c)
 	return nil
 }
 
+// addExcludeOptions adds the flags to select, tag, and exclude files and directories of a backup, see backupFlags.
+func addExcludeOptions(c *cobra.Command) {
+	f := c.Flags()
+	f.StringArray("files-from", []string{},
+		"read the paths to backup from a file, one path per line (can be specified multiple times)")
+	f.StringArray("tag", []string{}, "add a tag to the new snapshot (can be specified multiple times)")
+	f.StringArray("exclude", []string{}, "exclude a pattern (can be specified multiple times)")
+	f.StringArray("iexclude", []string{},
+		"same as --exclude but ignores the casing of filenames (can be specified multiple times)")
+	f.StringArray("exclude-file", []string{}, "read exclude patterns from a file (can be specified multiple times)")
+	f.StringArray("exclude-if-present", []string{},
+		"exclude a directory if it contains the given filename (can be specified multiple times)")
+	f.Bool("exclude-caches", false, "exclude directories containing a CACHEDIR.TAG file")
+	f.Bool("one-file-system", false, "exclude other file systems, do not cross filesystem boundaries")
+}
+
+// initConfigFlags assigns the values of the config file and environment to the flags matching match, unless the flags
+// are set on the command line. The config key of a flag uses underscores instead of dashes. Values of array flags are
+// read as a list, which is separated by spaces in the environment.
+func initConfigFlags(flags *pflag.FlagSet, match string) error {
+	re, err := regexp.Compile(match)
+	if err != nil {
+		return err
+	}
+
+	var setErr error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		key := strings.ReplaceAll(flag.Name, "-", "_")
+		if setErr != nil || flag.Changed || !re.MatchString(flag.Name) || !viper.IsSet(key) {
+			return
+		}
+		values := []string{viper.GetString(key)}
+		if flag.Value.Type() == "stringArray" {
+			values = viper.GetStringSlice(key)
+		}
+		for _, v := range values {
+			if err := flags.Set(flag.Name, v); err != nil {
+				setErr = fmt.Errorf("Invalid value '%s' for %s: %s", v, key, err.Error())
+				return
+			}
+		}
+	})
+	return setErr
+}
+
+// stdinSource returns the stdin command to backup instead of the backup path, or nil if no command is provided.
+func stdinSource() *lib.StdinSource {
+	if StdinCommand == "" {
+		return nil
+	}
+	return &lib.StdinSource{Command: StdinCommand, Filename: StdinFilename}
+}
+
+// initBackupSource reads the backup paths and the flags relayed to restic from the config file and environment, unless
+// set on the command line. The environment variable RESTIC_BACKUP_PATH separates multiple paths by ':'. It ensures
+// either a backup path (or files-from list) or a stdin command is provided, but not both.
+func initBackupSource(flags *pflag.FlagSet) error {
+	if !flags.Changed("path") && viper.IsSet("backup_path") {
+		if v, ok := viper.Get("backup_path").(string); ok {
+			BackupPaths = filepath.SplitList(v)
+		} else {
+			BackupPaths = viper.GetStringSlice("backup_path")
+		}
+	}
+	if err := initConfigFlags(flags, backupFlags); err != nil {
+		return err
+	}
+
+	hasPaths := len(BackupPaths) > 0 || flags.Changed("files-from")
+	if !hasPaths && StdinCommand == "" {
+		return errors.New("No backup path provided")
+	}
+	if hasPaths && StdinCommand != "" {
+		return errors.New("Cannot combine a backup path and a stdin command")
+	}
+	return stdinSource().Validate()
+}
+
+// initHooks reads the backup hooks from the config file. It returns an error if a hook is invalid.
+func initHooks() error {
+	if err := viper.UnmarshalKey("hooks", &BackupHooks); err != nil {
+		return fmt.Errorf("Could not read hooks: %s", err.Error())
+	}
+	return BackupHooks.Validate()
+}
+
+// initDocker reads the Docker containers to stop during a backup from the config file. It returns an error if the
+// options are invalid.
+func initDocker() error {
+	if err := viper.UnmarshalKey("docker", &BackupDocker); err != nil {
+		return fmt.Errorf("Could not read Docker options: %s", err.Error())
+	}
+	return BackupDocker.Validate()
+}
+
 // init registers the backupCmd with the rootCmd, which is managed by Cobra.
 func init() {
 	if err := addBackupOptions(backupCmd); err != nil {
 		lib.Logger.Fatal().Err(err).Msg("Could not initialize backup options")
 	}
+	addProfileOption(backupCmd)
 	rootCmd.AddCommand(backupCmd)
 }
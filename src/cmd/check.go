@@ -12,21 +12,35 @@ import (
 // Variables
 //======================================================================================================================
 
-// snapshotsCmd represents the snapshots command
+// DataSubset defines the subset of the data packs to read when checking the repository.
+var DataSubset string
+
+// checkCmd represents the check command
 var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Test the repository for errors",
 	Long: `
 The "check" command tests the repository for errors and reports any errors it
 finds. By default, the "check" command will always load all data directly from the
-repository and not use a local cache.`,
+repository and not use a local cache. Use the flag --profile to check one or more
+repository profiles defined in the config file, or 'all' to check all profiles.
+
+Examples:
+restic-unattended check --read-data-subset 1/5
+Checks the repository and verifies the data of the first fifth of all pack
+files. See the "schedule" command to rotate through the subsets automatically.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return initProfiles(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		f := func() error {
-			r, err := lib.NewResticManager()
-			if err != nil {
-				return err
+			var args []string
+			if DataSubset != "" {
+				args = append(args, "--read-data-subset="+DataSubset)
 			}
-			return r.Check()
+			return forEachRepository(func(r *lib.ResticManager) error {
+				return r.Check(args...)
+			})
 		}
 		lib.HandleCmd(f, "Error executing check", false)
 	},
@@ -38,5 +52,8 @@ repository and not use a local cache.`,
 
 // init registers the snapshotsCmd with the rootCmd, which is managed by Cobra.
 func init() {
+	checkCmd.Flags().StringVar(&DataSubset, "read-data-subset", "",
+		"read a subset of the data packs, specified as 'n/t', percentage (e.g. '5%'), or size (e.g. '500M')")
+	addProfileOption(checkCmd)
 	rootCmd.AddCommand(checkCmd)
 }
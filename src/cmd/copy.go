@@ -0,0 +1,75 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// CopyFilter defines the host, tags, and paths to select the snapshots to copy by.
+var CopyFilter lib.SnapshotFilter
+
+// CopyInit initializes the destination repository if it does not exist yet.
+var CopyInit bool
+
+// copyCmd represents the copy command
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy snapshots from a source repository",
+	Long: `
+The "copy" command copies snapshots from a source repository to the destination
+repository, without rescanning the backup source. This allows to backup once to
+a local repository, and to replicate the snapshots offsite later on.
+
+The destination repository is defined by the regular environment variables or
+repository profile. The source repository is defined by the variables
+RESTIC_FROM_REPOSITORY and RESTIC_FROM_PASSWORD. Both variables support
+file-based secrets too, e.g. RESTIC_FROM_PASSWORD_FILE. Add the flag --init to
+initialize the destination repository with the chunker parameters of the source
+repository, which ensures the copied data is deduplicated efficiently.
+
+Examples:
+restic-unattended copy --init
+Copies all snapshots from the source repository and initializes the destination
+repository if needed.
+
+restic-unattended copy --host myhost --profile b2
+Copies all snapshots of host "myhost" to the repository profile "b2".
+`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return initProfiles(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			return forEachRepository(func(r *lib.ResticManager) error {
+				return r.Copy(CopyFilter, CopyInit)
+			})
+		}
+		lib.HandleCmd(f, "Error running copy", false)
+	},
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// init registers the copyCmd with the rootCmd, which is managed by Cobra. It defines several optional flags to filter
+// the snapshots to copy.
+func init() {
+	f := copyCmd.Flags()
+	f.BoolVar(&CopyInit, "init", false, "initialize the destination repository if it does not exist yet")
+	f.StringVarP(&CopyFilter.Host, "host", "H", "", "only copy snapshots for this host")
+	f.StringArrayVar(&CopyFilter.Tags, "tag", []string{},
+		"only copy snapshots which include this taglist (can be specified multiple times)")
+	f.StringArrayVar(&CopyFilter.Paths, "path", []string{},
+		"only copy snapshots which include this (absolute) path (can be specified multiple times)")
+	addProfileOption(copyCmd)
+	f.SortFlags = false
+	rootCmd.AddCommand(copyCmd)
+}
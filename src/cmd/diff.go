@@ -0,0 +1,84 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// DiffFilter defines the host, tags, and paths to select the latest two snapshots by.
+var DiffFilter lib.SnapshotFilter
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [snapshot-id snapshot-id]",
+	Short: "Show differences between two snapshots",
+	Long: `
+The "diff" command compares two snapshots and summarizes the number of added,
+removed, and modified files, and the change in size. Without arguments, the
+latest snapshot is compared with the snapshot before it. These snapshots can be
+narrowed down by host, tag, and path. The output is rendered as a table by
+default, use "--output json" or "--output yaml" for machine-readable output.
+
+Examples:
+restic-unattended diff
+Compares the latest snapshot with the snapshot before it
+
+restic-unattended diff 5845b002 2ab627a6 --output json
+Compares two specific snapshots and prints the summary in JSON format
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return errors.New("requires either no or two snapshot arguments")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := lib.ParseOutputFormat(OutputFormat)
+		return err
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			r, err := lib.NewResticManager()
+			if err != nil {
+				return err
+			}
+			format, err := lib.ParseOutputFormat(OutputFormat)
+			if err != nil {
+				return err
+			}
+			var source, target string
+			if len(args) == 2 {
+				source, target = args[0], args[1]
+			}
+			return r.ShowDiff(source, target, DiffFilter, format)
+		}
+		lib.HandleCmd(f, "Error comparing snapshots", false)
+	},
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// init registers the diffCmd with the rootCmd, which is managed by Cobra. It defines several optional flags to
+// select the snapshots and to specify the output format.
+func init() {
+	f := diffCmd.Flags()
+	f.StringVarP(&DiffFilter.Host, "host", "H", "", "only consider snapshots for this host")
+	f.StringArrayVar(&DiffFilter.Tags, "tag", []string{},
+		"only consider snapshots which include this taglist (can be specified multiple times)")
+	f.StringArrayVar(&DiffFilter.Paths, "path", []string{},
+		"only consider snapshots which include this (absolute) path (can be specified multiple times)")
+	f.StringVarP(&OutputFormat, "output", "o", "table", "output format to use: table, json, yaml")
+	f.SortFlags = false
+	rootCmd.AddCommand(diffCmd)
+}
@@ -12,6 +12,9 @@ import (
 // Variables
 //======================================================================================================================
 
+// NoPrune skips pruning of the repository after forgetting snapshots.
+var NoPrune bool
+
 // forgetCmd represents the forget command
 var forgetCmd = &cobra.Command{
 	Use:   "forget",
@@ -19,7 +22,8 @@ var forgetCmd = &cobra.Command{
 	Long: `
 Forget removes old backups according to a rotation schedule. It both flags 
 snapshots for removal as well as deletes (prunes) the actual old snapshot from
-the repository.
+the repository. Add the flag --no-prune to skip the (potentially expensive)
+prune operation, and use the "prune" command to remove the data later on.
 
 Examples:
 restic-unattended forget --keep-last 5
@@ -27,18 +31,23 @@ Keep the 5 most recent snapshots
 
 restic-unattended forget --keep-daily 7
 Keep the most recent backup for each of the last 7 days
+
+restic-unattended forget --keep-daily 7 --profile all
+Keep the most recent backup for each of the last 7 days in all repository
+profiles defined in the config file
 `,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return initProfiles(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		f := func() error {
-			r, err := lib.NewResticManager()
-			if err != nil {
-				return err
-			}
 			args, err := lib.ParseArgs(cmd.Flags(), "^keep-")
 			if err != nil {
 				return err
 			}
-			return r.Forget(args)
+			return forEachRepository(func(r *lib.ResticManager) error {
+				return r.Forget(args, !NoPrune)
+			})
 		}
 		lib.HandleCmd(f, "Error running forget", false)
 	},
@@ -71,6 +80,8 @@ func addKeepOptions(c *cobra.Command) {
 // the exact backup rotation schedule.
 func init() {
 	addKeepOptions(forgetCmd)
+	forgetCmd.Flags().BoolVar(&NoPrune, "no-prune", false, "skip removal of unreferenced data from the repository")
+	addProfileOption(forgetCmd)
 	forgetCmd.Flags().SortFlags = false
 	rootCmd.AddCommand(forgetCmd)
 }
@@ -0,0 +1,112 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// StatusFile defines the path of the status file written by the schedule command.
+var StatusFile string
+
+// MaxAge defines the maximum age of the last successful job, a value of zero disables the age check.
+var MaxAge time.Duration
+
+// HealthTags defines the tags of the jobs to validate.
+var HealthTags []string
+
+// HealthIgnoreMissing reports a healthy state if the status file does not exist, which is the case when the schedule
+// command is not running.
+var HealthIgnoreMissing bool
+
+// healthCmd represents the health command
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Check the health of a running schedule",
+	Long: `
+The "health" command validates the status file written by a running "schedule"
+command. It exits with a non-zero code if the last backup failed, or if the last
+successful backup is older than the maximum age. The command is intended to be
+used as Docker HEALTHCHECK. Use --ignore-missing to report a healthy state if
+the status file does not exist, e.g. when the container runs a one-off command
+instead of "schedule".
+
+Examples:
+restic-unattended health --max-age 25h
+Fails if the last backup failed, or if there was no successful backup within the
+last 25 hours.
+
+restic-unattended health --tag backup --tag forget
+Fails if the last backup or forget job failed.
+`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := initStatusFile(cmd); err != nil {
+			return err
+		}
+		MaxAge = viper.GetDuration("max_age")
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			status, err := lib.ReadStatusFile(StatusFile)
+			if HealthIgnoreMissing && errors.Is(err, os.ErrNotExist) {
+				lib.Logger.Info().Msgf("Status file '%s' not found, skipping health check", StatusFile)
+				return nil
+			}
+			if err != nil {
+				return &lib.ResticError{Err: err.Error(), Fatal: true}
+			}
+			if err := lib.CheckHealth(status, HealthTags, MaxAge, time.Now()); err != nil {
+				return &lib.ResticError{Err: err.Error(), Fatal: true}
+			}
+			lib.Logger.Info().Msg("Healthy")
+			return nil
+		}
+		lib.HandleCmd(f, "Unhealthy", false)
+	},
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// addStatusFileOption adds the "status-file" flag to a command.
+func addStatusFileOption(c *cobra.Command) {
+	c.Flags().String("status-file", lib.DefaultStatusFile, "path of the status file written by the schedule command")
+}
+
+// initStatusFile binds the "status-file" flag of the executing command to the environment variables and initializes
+// StatusFile. The flag is bound when the command runs, as it is shared by the health and schedule commands.
+func initStatusFile(c *cobra.Command) error {
+	if err := viper.BindPFlag("status_file", c.Flags().Lookup("status-file")); err != nil {
+		return fmt.Errorf("Could not bind status_file flag")
+	}
+	StatusFile = viper.GetString("status_file")
+	return nil
+}
+
+// init registers the healthCmd with the rootCmd, which is managed by Cobra.
+func init() {
+	addStatusFileOption(healthCmd)
+	healthCmd.Flags().Duration("max-age", 0, "maximum age of the last successful job (e.g. 25h)")
+	if err := viper.BindPFlag("max_age", healthCmd.Flags().Lookup("max-age")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind max_age flag")
+	}
+	healthCmd.Flags().StringArrayVar(&HealthTags, "tag", []string{"backup"},
+		"tag of the job to validate (can be specified multiple times)")
+	healthCmd.Flags().BoolVar(&HealthIgnoreMissing, "ignore-missing", false,
+		"report healthy if the status file does not exist (schedule command not running)")
+	rootCmd.AddCommand(healthCmd)
+}
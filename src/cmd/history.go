@@ -0,0 +1,115 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// HistoryTags defines the tags of the jobs to show the history of.
+var HistoryTags []string
+
+// HistorySince defines the start of the time range to show the history of.
+var HistorySince string
+
+// HistoryUntil defines the end of the time range to show the history of.
+var HistoryUntil string
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the history of scheduled jobs",
+	Long: `
+The "history" command shows the runs of the jobs executed by the "schedule"
+command. Each run is appended to the file 'history.jsonl' in the state
+directory (defaults to /data/state), including its start and end time, result,
+error, and backup summary. The history survives restarts and is never
+modified, which provides an audit trail of the backups.
+
+The runs can be filtered by job tag and by time range. A tag also matches the
+jobs of a backup set or repository profile, e.g. 'backup' matches
+'backup-documents@b2'. The time range is defined by a timestamp (e.g.
+'2022-01-31T22:00:00Z'), a date (e.g. '2022-01-31'), or a duration relative to
+now (e.g. '24h'). The output is rendered as a table by default, use
+"--output json" or "--output yaml" for machine-readable output.
+
+Examples:
+restic-unattended history --tag backup --since 168h
+Shows the backup runs of the last seven days
+
+restic-unattended history --since 2022-01-01 --until 2022-02-01 --output json
+Shows all runs of January 2022 in JSON format
+`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := initStateDir(cmd); err != nil {
+			return err
+		}
+		_, err := lib.ParseOutputFormat(OutputFormat)
+		return err
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			format, err := lib.ParseOutputFormat(OutputFormat)
+			if err != nil {
+				return err
+			}
+			filter := lib.HistoryFilter{Tags: HistoryTags}
+			now := time.Now()
+			if HistorySince != "" {
+				if filter.Since, err = lib.ParseHistoryTime(HistorySince, now); err != nil {
+					return err
+				}
+			}
+			if HistoryUntil != "" {
+				if filter.Until, err = lib.ParseHistoryTime(HistoryUntil, now); err != nil {
+					return err
+				}
+			}
+			return lib.ShowHistory(StateDir, filter, format)
+		}
+		lib.HandleCmd(f, "Error showing history", false)
+	},
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// addStateDirOption adds the "state-dir" flag to a command.
+func addStateDirOption(c *cobra.Command) {
+	c.Flags().String("state-dir", lib.DefaultStateDir, "directory to persist state across restarts")
+}
+
+// initStateDir binds the "state-dir" flag of the executing command to the environment variables and initializes
+// StateDir. The flag is bound when the command runs, as it is shared by the history and schedule commands.
+func initStateDir(c *cobra.Command) error {
+	if err := viper.BindPFlag("state_dir", c.Flags().Lookup("state-dir")); err != nil {
+		return fmt.Errorf("Could not bind state_dir flag")
+	}
+	StateDir = viper.GetString("state_dir")
+	return nil
+}
+
+// init registers the historyCmd with the rootCmd, which is managed by Cobra. It defines several optional flags to
+// select the runs and to specify the output format.
+func init() {
+	f := historyCmd.Flags()
+	f.StringArrayVar(&HistoryTags, "tag", []string{},
+		"only show the runs of this job tag (can be specified multiple times)")
+	f.StringVar(&HistorySince, "since", "", "only show runs started at or after this time, date, or duration ago")
+	f.StringVar(&HistoryUntil, "until", "", "only show runs started at or before this time, date, or duration ago")
+	f.StringVarP(&OutputFormat, "output", "o", "table", "output format to use: table, json, yaml")
+	addStateDirOption(historyCmd)
+	f.SortFlags = false
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,88 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// Profiles defines the names of the targeted repository profiles, the repository defined by the environment is
+// targeted if empty.
+var Profiles []string
+
+// RepositoryProfiles defines the available repository profiles, as defined in the config file.
+var RepositoryProfiles []lib.RepositoryProfile
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// addProfileOption adds the "profile" flag to a command.
+func addProfileOption(c *cobra.Command) {
+	c.Flags().StringArray("profile", []string{},
+		"repository profile to target, or 'all' to target all profiles (can be specified multiple times)")
+}
+
+// forEachRepository invokes f for each targeted repository. Errors of individual repositories are logged, so that
+// the remaining repositories are still processed. It returns the last error, if any.
+func forEachRepository(f func(r *lib.ResticManager) error) error {
+	managers, err := lib.NewResticManagers(RepositoryProfiles, Profiles)
+	if err != nil {
+		return err
+	}
+
+	var ret error
+	for _, r := range managers {
+		if r.Profile() != "" {
+			lib.Logger.Info().Msgf("Targeting repository profile '%s'", r.Profile())
+		}
+		if err := f(r); err != nil {
+			if len(managers) > 1 {
+				lib.Logger.Error().Err(err).Msgf("Error targeting repository profile '%s'", r.Profile())
+			}
+			ret = err
+		}
+	}
+	return ret
+}
+
+// initProfiles binds the "profile" flag of the executing command to the environment variables and reads the
+// repository profiles from the config file. The flag is bound when the command runs, as it is shared by several
+// commands. It returns an error if a profile is invalid, or if a targeted profile cannot be found.
+func initProfiles(c *cobra.Command) error {
+	if err := viper.BindPFlag("profile", c.Flags().Lookup("profile")); err != nil {
+		return fmt.Errorf("Could not bind profile flag")
+	}
+	Profiles = viper.GetStringSlice("profile")
+
+	if err := viper.UnmarshalKey("repositories", &RepositoryProfiles); err != nil {
+		return fmt.Errorf("Could not read repository profiles: %s", err.Error())
+	}
+	if err := lib.ValidateProfiles(RepositoryProfiles); err != nil {
+		return err
+	}
+	_, err := lib.SelectProfiles(RepositoryProfiles, Profiles)
+	return err
+}
+
+// newRepositories creates a restic manager for each available repository profile, identified by name.
+func newRepositories() (map[string]*lib.ResticManager, error) {
+	repositories := map[string]*lib.ResticManager{}
+	for _, p := range RepositoryProfiles {
+		r, err := lib.NewResticManagerWithProfile(p)
+		if err != nil {
+			return nil, err
+		}
+		repositories[p.Name] = r
+	}
+	return repositories, nil
+}
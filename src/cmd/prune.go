@@ -0,0 +1,72 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unreferenced data from the repository",
+	Long: `
+Prune removes data from the repository that is no longer referenced by any
+snapshot, such as the data of snapshots removed by the "forget" command. Prune
+repacks partially used data files, which can be an expensive operation on cloud
+storage. Use --max-unused and --max-repack-size to limit the amount of data to
+repack.
+
+Examples:
+restic-unattended prune --max-unused 10%
+Tolerates up to 10% of unused data in the repository to reduce repacking
+
+restic-unattended prune --max-repack-size 2G
+Repacks at most 2 GiB of data in a single run
+`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return initProfiles(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			args, err := lib.ParseArgs(cmd.Flags(), pruneFlags)
+			if err != nil {
+				return err
+			}
+			return forEachRepository(func(r *lib.ResticManager) error {
+				return r.Prune(args)
+			})
+		}
+		lib.HandleCmd(f, "Error running prune", false)
+	},
+}
+
+// pruneFlags matches the names of the flags relayed to the prune command.
+const pruneFlags = "^(max-unused|max-repack-size|repack-cacheable-only)$"
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+func addPruneOptions(c *cobra.Command) {
+	f := c.Flags()
+	f.String("max-unused", "", "tolerate given limit of unused data (absolute value in bytes with suffixes k/K, m/M, "+
+		"g/G, t/T, a value in % or the word 'unlimited')")
+	f.String("max-repack-size", "", "maximum size to repack (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.Bool("repack-cacheable-only", false, "only repack packs which are cacheable")
+}
+
+// init registers the pruneCmd with the rootCmd, which is managed by Cobra. It adds several flags to limit the amount of
+// data to repack.
+func init() {
+	addPruneOptions(pruneCmd)
+	addProfileOption(pruneCmd)
+	pruneCmd.Flags().SortFlags = false
+	rootCmd.AddCommand(pruneCmd)
+}
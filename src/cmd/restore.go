@@ -21,13 +21,27 @@ var RestorePath string
 // Snapshot defines the snapshot ID to restore from (defaults to "latest")
 var Snapshot string = "latest"
 
+// RestoreOptions defines the snapshot filter, include and exclude patterns, and verification of the restore.
+var RestoreOptions lib.RestoreOptions
+
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
 	Use:   "restore <path>",
 	Short: "Restore a remote backup to a local path",
 	Long: `
-Restores a backup stored in a restic repository to a local path.
-	`,
+Restores a backup stored in a restic repository to a local path. By default,
+the latest snapshot is restored entirely. The snapshot considered 'latest' can
+be narrowed down by host, tag, and path. Use --include and --exclude to restore
+specific files and directories only, and --verify to verify the restored files
+after the restore.
+
+Examples:
+restic-unattended restore /data/restore --include /data/docs/report.pdf
+Restores a single file from the latest snapshot
+
+restic-unattended restore /data/restore --tag daily --path /data/docs --verify
+Restores and verifies the latest snapshot of /data/docs tagged with "daily"
+`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("requires a path argument")
@@ -44,7 +58,9 @@ Restores a backup stored in a restic repository to a local path.
 			if err != nil {
 				return err
 			}
-			return r.Restore(RestorePath, Snapshot)
+			opts := RestoreOptions
+			opts.Snapshot, opts.Target = Snapshot, RestorePath
+			return r.RestoreWithOptions(opts)
 		}
 		lib.HandleCmd(f, "Error running restore", false)
 	},
@@ -54,9 +70,24 @@ Restores a backup stored in a restic repository to a local path.
 // Private Functions
 //======================================================================================================================
 
-// init registers the restoreCmd with the rootCmd, which is managed by Cobra.
+// init registers the restoreCmd with the rootCmd, which is managed by Cobra. It defines several optional flags to
+// select the snapshot and the files to restore.
 func init() {
-	restoreCmd.Flags().StringVarP(&Snapshot, "snapshot", "", "latest",
-		"ID of the snapshot to restore")
+	f := restoreCmd.Flags()
+	f.StringVarP(&Snapshot, "snapshot", "", "latest", "ID of the snapshot to restore")
+	f.StringVarP(&RestoreOptions.Filter.Host, "host", "H", "",
+		"only consider snapshots for this host when the snapshot ID is 'latest'")
+	f.StringArrayVar(&RestoreOptions.Filter.Tags, "tag", []string{},
+		"only consider snapshots which include this taglist when the snapshot ID is 'latest' (can be specified "+
+			"multiple times)")
+	f.StringArrayVar(&RestoreOptions.Filter.Paths, "path", []string{},
+		"only consider snapshots which include this (absolute) path when the snapshot ID is 'latest' (can be "+
+			"specified multiple times)")
+	f.StringArrayVarP(&RestoreOptions.Includes, "include", "i", []string{},
+		"include a pattern, exclude everything else (can be specified multiple times)")
+	f.StringArrayVarP(&RestoreOptions.Excludes, "exclude", "e", []string{},
+		"exclude a pattern (can be specified multiple times)")
+	f.BoolVar(&RestoreOptions.Verify, "verify", false, "verify restored files content")
+	f.SortFlags = false
 	rootCmd.AddCommand(restoreCmd)
 }
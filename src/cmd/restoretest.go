@@ -0,0 +1,76 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/markdumay/restic-unattended/lib"
+	"github.com/spf13/cobra"
+)
+
+//======================================================================================================================
+// Variables
+//======================================================================================================================
+
+// RestoreTest defines the snapshot filter, sample size, manifest, and directory of a restore test.
+var RestoreTest lib.RestoreTestOptions
+
+// restoreTestCmd represents the restore-test command
+var restoreTestCmd = &cobra.Command{
+	Use:   "restore-test",
+	Short: "Verify that the latest snapshot can be restored",
+	Long: `
+The "restore-test" command restores the latest snapshot into a temporary
+directory and compares the checksums of the restored files against the live
+source. Files modified or removed since the snapshot was taken are skipped. Use
+--sample to restore a random sample of files instead of the entire snapshot, and
+--manifest to compare against a checksum file in the format of 'sha256sum'
+instead of the live source. When restoring the entire snapshot, files listed in
+the manifest that were not restored are reported too. Any differences are
+reported, the temporary directory is removed afterwards. The command fails if
+any differences are found.
+
+The temporary directory is created in the system's temporary directory, unless
+specified by --dir. Note that the temporary directory of the Docker image is
+small, use a volume such as /data/restore when restoring entire snapshots.
+
+Examples:
+restic-unattended restore-test --path /data --sample 100
+Restores 100 random files of the latest snapshot of /data and verifies them.
+
+restic-unattended restore-test --manifest /data/manifest.sha256 --dir /data/restore
+Restores the latest snapshot in /data/restore and verifies it using a manifest.
+See the "schedule" command to run restore tests on schedule.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return initProfiles(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f := func() error {
+			return forEachRepository(func(r *lib.ResticManager) error {
+				_, err := r.RestoreTest(RestoreTest)
+				return err
+			})
+		}
+		lib.HandleCmd(f, "Error running restore test", false)
+	},
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// init registers the restoreTestCmd with the rootCmd, which is managed by Cobra.
+func init() {
+	f := restoreTestCmd.Flags()
+	f.StringVarP(&RestoreTest.Filter.Host, "host", "H", "", "only consider snapshots for this host")
+	f.StringArrayVar(&RestoreTest.Filter.Tags, "tag", []string{},
+		"only consider snapshots which include this taglist (can be specified multiple times)")
+	f.StringArrayVar(&RestoreTest.Filter.Paths, "path", []string{},
+		"only consider snapshots which include this (absolute) path (can be specified multiple times)")
+	f.IntVar(&RestoreTest.Sample, "sample", 0, "number of random files to restore (defaults to the entire snapshot)")
+	f.StringVar(&RestoreTest.Manifest, "manifest", "", "checksum file to verify against instead of the live source")
+	f.StringVar(&RestoreTest.Dir, "dir", "", "directory to create the temporary restore directory in")
+	addProfileOption(restoreTestCmd)
+	f.SortFlags = false
+	rootCmd.AddCommand(restoreTestCmd)
+}
@@ -5,7 +5,11 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/markdumay/restic-unattended/lib"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -23,13 +27,71 @@ var BackupCron string
 // ForgetCron defines the schedule for the forget cron job, similar to BackupCron.
 var ForgetCron string
 
+// CopyCron defines the schedule for the copy cron job, similar to BackupCron.
+var CopyCron string
+
+// CheckCron defines the schedule for the check cron job, similar to BackupCron.
+var CheckCron string
+
+// CheckSubset defines the data subset verified by the check cron job, see lib.ResticManager.CheckSubset.
+var CheckSubset string
+
+// PruneCron defines the schedule for the prune cron job, similar to BackupCron. Forget jobs no longer prune the
+// repository themselves if set.
+var PruneCron string
+
+// Diff defines if each backup job compares its new snapshot with the previous snapshot.
+var Diff bool
+
+// RestoreTestCron defines the schedule for the restore test cron job, similar to BackupCron.
+var RestoreTestCron string
+
+// StateDir defines the directory to persist state and the job history across restarts.
+var StateDir string
+
 // Sustained defines if processing of scheduled jobs should continue despite errors
 var Sustained bool
 
-// scheduleCmd represents the schedule command. It sets up a job that is repeated following a cron schedule. It requires
-// one argument that represents the cron spec.
+// Notifications defines the notifications sent when a job finishes or is dropped, as defined in the config file.
+var Notifications []*lib.Notification
+
+// Pings defines the monitoring URLs to ping for each job, identified by tag, as defined in the config file.
+var Pings map[string]*lib.PingConfig
+
+// BackupSets defines the named backup sets to schedule, as defined in the config file.
+var BackupSets []lib.BackupSet
+
+// JobTimeout defines the maximum duration of each scheduled job, the duration is unlimited if zero.
+var JobTimeout time.Duration
+
+// JobTimeouts defines the maximum duration of the jobs identified by tag, as defined in the config file. It overrides
+// JobTimeout.
+var JobTimeouts map[string]time.Duration
+
+// Catchup defines the policy for runs missed while the scheduler was not running: none, once, or all.
+var Catchup string
+
+// Catchups defines the catch-up policy of the jobs identified by tag, as defined in the config file. It overrides
+// Catchup.
+var Catchups map[string]string
+
+// DrainPeriod defines how long a running job may take to finish once the scheduler receives a termination signal.
+var DrainPeriod time.Duration
+
+// scheduleFlags matches the schedule flags that can be set in the config file and environment too, see initConfigFlags
+const scheduleFlags = "^(forget|copy|prune|check|read-data-subset|diff|restore-test(-.*)?|keep-.*|max-unused|" +
+	"max-repack-size|repack-cacheable-only)$"
+
+// cliFlags records the flags set on the command line, which take precedence over the config file on reload.
+var cliFlags map[string]bool
+
+// Listen defines the address of the HTTP status and control API (e.g. ':8080'), the API is disabled if empty.
+var Listen string
+
+// scheduleCmd represents the schedule command. It sets up a job that is repeated following a cron schedule. It accepts
+// one argument that represents the cron spec, which is optional if backup sets are defined in the config file.
 var scheduleCmd = &cobra.Command{
-	Use:   "schedule <cron>",
+	Use:   "schedule [cron]",
 	Short: "Run a backup using cron schedule",
 	Long: `
 Schedule sets up a backup job that is repeated following a cron schedule. It
@@ -86,10 +148,186 @@ backups are kept for each of the last 7 days, determined at 00:00 every day.
 
 restic-unattended schedule '@weekly'
 Runs a scheduled backup once a week at midnight on Sunday.
+
+restic-unattended schedule '@daily' --forget '0 1 * * *' --keep-daily 7 \
+    --prune '0 2 * * SUN' --max-unused 10%
+Runs a scheduled backup every day and removes old snapshots at 01:00 every
+day. The unreferenced data is pruned weekly on Sunday at 02:00, instead of
+after each forget job.
+
+restic-unattended schedule '@daily' --check '0 3 * * *' --read-data-subset 7
+Runs a scheduled backup every day and checks the repository at 03:00 every day.
+Each check reads a different seventh of the repository data, verifying all data
+once a week. The position of the rotation is persisted in the state directory
+(defaults to /data/state) and survives restarts.
+
+restic-unattended schedule '@daily' --restore-test '0 5 * * SAT' \
+    --restore-test-sample 100
+Runs a scheduled backup every day and verifies 100 random files of the latest
+snapshot every Saturday at 05:00, see the "restore-test" command for details.
+The latest snapshot is selected by the host and paths of the backup job.
+
+restic-unattended schedule '@daily' --copy '0 4 * * *' --profile b2
+Runs a scheduled backup every day and copies all snapshots from the source
+repository to the b2 repository profile at 04:00 every day. See the "copy"
+command for the definition of the source repository.
+
+restic-unattended schedule '@daily' --listen ':8080'
+Runs a scheduled backup every day and exposes an HTTP API on port 8080. The API
+supports the following endpoints:
+GET  /status             status of the scheduler and all jobs
+GET  /jobs               status of all jobs
+GET  /jobs/<tag>         status of a single job (e.g. /jobs/backup)
+POST /jobs/<tag>/run     run a job now
+POST /jobs/<tag>/pause   pause a job, skipping scheduled runs
+POST /jobs/<tag>/resume  resume a paused job
+GET  /metrics            job metrics in Prometheus text format
+
+The status of all jobs is written to a status file after each job (defaults to
+/tmp/restic-unattended.status). Use the "health" command to validate it. Each
+run is appended to the job history in the state directory too, use the
+"history" command to query it.
+
+Notifications are defined in the config file. Each notification has a type
+(webhook, smtp, or script) and is triggered by selected job tags and outcomes
+(success, failure, fatal, or dropped). For example:
+
+notifications:
+  - type: webhook
+    url: https://hooks.example.com/backup
+    body: '{"text": "Job {{.Tag}} on {{.Hostname}}: {{.Outcome}} {{json .Error}}"}'
+    tags: [backup]
+    events: [failure, fatal, dropped]
+  - type: smtp
+    host: smtp.example.com
+    port: 587
+    username: alerts
+    password_file: /run/secrets/SMTP_PASSWORD
+    from: alerts@example.com
+    to: [ops@example.com]
+  - type: script
+    command: /usr/local/bin/notify.sh
+
+Each backup job compares its new snapshot with the previous snapshot if the
+flag --diff is set. The number of added, removed, and modified files and the
+change in size are logged and included in the summary of the job, which is
+available to notifications. A sudden drop in files or a large change in size
+can indicate ransomware or a misconfiguration. For example:
+
+notifications:
+  - type: webhook
+    url: https://hooks.example.com/backup
+    body: '{"text": "{{.Tag}}: {{with .Summary}}{{with .Diff}}+{{.FilesAdded}}
+      -{{.FilesRemoved}} ~{{.FilesModified}} ({{.SizeDelta}} bytes){{end}}{{end}}"}'
+    tags: [backup]
+    events: [success]
+
+Multiple backup sets can be defined in the config file instead of a single
+backup path. Each set is scheduled as a job with tag 'backup-<name>' and an
+optional job with tag 'forget-<name>' applying its retention policy. The cron
+argument is optional if backup sets are defined. For example:
+
+backup_sets:
+  - name: documents
+    paths: [/data/documents, /data/photos]
+    excludes: ['*.tmp']
+    tags: [documents]
+    cron: '0 0 * * *'
+    forget: '0 3 * * SUN'
+    retention:
+      daily: 7
+      weekly: 4
+  - name: database
+    paths: [/data/db]
+    host: db-server
+    repository: s3:s3.amazonaws.com/bucket/db
+    cron: '0 * * * *'
+    hooks:
+      pre:
+        - command: /usr/local/bin/dump-db.sh
+          timeout: 10m
+  - name: mysql
+    stdin:
+      command: mysqldump -h mysql --all-databases
+      filename: all-databases.sql
+    cron: '0 2 * * *'
+
+Jobs target the repository defined by the environment, unless repository
+profiles are selected with the flag --profile (or 'profiles' for a backup set).
+Each profile is scheduled as a separate job with the profile name as suffix,
+e.g. 'backup@nas' and 'backup-documents@b2'. See the "backup" command for an
+example of repository profiles.
+
+Hooks and Docker containers defined in the config file apply to each backup
+job, see the "backup" command for an example. Backup sets define their own
+hooks and Docker containers. A backup set with a 'stdin' command backs up the
+output of the command instead of its paths, like the flag --stdin-command.
+
+Each job can ping a monitoring service such as healthchecks.io or Uptime Kuma
+when it starts, succeeds, or fails. The failure ping includes the exit status and
+the last log lines of the job. Pings are defined by job tag in the config file:
+
+pings:
+  backup:
+    start: https://hc-ping.com/<uuid>/start
+    success: https://hc-ping.com/<uuid>
+    failure: https://hc-ping.com/<uuid>/fail
+    log_lines: 20
+
+A job is interrupted once it exceeds the duration set by the flag --timeout,
+for example when a remote repository stops responding. Restic receives an
+interrupt signal first, allowing it to clean up, and is killed if it does not
+terminate within the grace period set by the flag --grace-period (defaults to
+30s). The same applies when the scheduler itself is interrupted. Timeouts can
+be set per job tag in the config file, a tag also matches the jobs of a backup
+set or repository profile (e.g. 'backup' matches 'backup-documents@b2'):
+
+timeouts:
+  backup: 6h
+  check: 12h
+  backup-database: 30m
+
+The scheduler stops on SIGINT, SIGTERM, or SIGHUP. No new jobs are started, but
+a running job may take up to the duration set by the flag --drain-period to
+finish (defaults to 0s). The job is interrupted after this period, or as soon
+as a second signal is received. The final state of all jobs is logged and
+written to the status file before exiting. Ensure the container runtime waits
+long enough before killing the process, e.g. 'stop_grace_period' in Docker
+Compose should exceed the drain period and grace period combined.
+
+Runs missed while the scheduler was not running, e.g. because the host was
+rebooting at the scheduled time, are detected on startup using the job history
+in the state directory. The flag --catchup defines how missed runs are handled:
+'none' skips them (default), 'once' runs the job once, and 'all' runs the job
+for each missed run (up to 5). Jobs that have not run before are not caught up.
+Catch-up policies can be set per job tag in the config file too:
+
+catchups:
+  backup: once
+  check: none
+
+The jobs are rebuilt without restarting when the scheduler receives SIGHUP, or
+when the config file changes. Jobs are matched by tag: new jobs are scheduled,
+removed jobs are unscheduled, and changed jobs are updated while retaining
+their status. A running job is not interrupted and completes as before. The
+listen address, status file, state directory, notifications, grace period, and
+drain period require a restart. Next to the config sections shown above, the
+cron spec ('cron') and the flags of the schedule command can be set in the
+config file, using underscores instead of dashes. Flags set on the command line
+take precedence. For example:
+
+cron: '@daily'
+forget: '0 1 * * *'
+keep_daily: 7
+keep_weekly: 4
+backup_path: [/data/docs, /data/photos]
 `,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) != 1 {
-			return errors.New("requires a cron argument")
+		if len(args) > 1 {
+			return errors.New("accepts at most one cron argument")
+		}
+		if len(args) == 0 {
+			return nil
 		}
 		if err := lib.IsValidCron(args[0]); err != nil {
 			return err
@@ -98,20 +336,24 @@ Runs a scheduled backup once a week at midnight on Sunday.
 		return nil
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// remember the flags set on the command line, which take precedence on reload
+		cliFlags = map[string]bool{}
+		cmd.Flags().Visit(func(flag *pflag.Flag) { cliFlags[flag.Name] = true })
 		initScheduleFlags(cmd.Flags())
-		return validateScheduleFlags(cmd.Flags())
+		if err := initStateDir(cmd); err != nil {
+			return err
+		}
+		return initSchedule(cmd, args)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		f := func() error {
-			r, err := lib.NewResticManager()
-			if err != nil {
-				return err
-			}
-			args, err := lib.ParseArgs(cmd.Flags(), "^keep-")
+			r, opts, err := newSchedule(cmd)
 			if err != nil {
 				return err
 			}
-			return r.Schedule(BackupCron, ForgetCron, BackupPath, InitRepository, Host, Sustained, args)
+			opts.Reload = func() ([]lib.Job, error) { return reloadSchedule(cmd, args) }
+			opts.Watch = watchConfig
+			return r.Schedule(opts)
 		}
 		lib.HandleCmd(f, "Error running schedule command", true)
 	},
@@ -124,31 +366,364 @@ Runs a scheduled backup once a week at midnight on Sunday.
 // init registers the scheduleCmd with the rootCmd, which is managed by Cobra.
 func init() {
 	scheduleCmd.Flags().StringVar(&ForgetCron, "forget", "", "remove old snapshots according to rotation schedule.")
+	scheduleCmd.Flags().StringVar(&CopyCron, "copy", "", "copy snapshots from the source repository on schedule")
+	scheduleCmd.Flags().StringVar(&PruneCron, "prune", "",
+		"remove unreferenced data on schedule, instead of after each forget job")
+	scheduleCmd.Flags().StringVar(&CheckCron, "check", "", "check the repository for errors on schedule")
+	scheduleCmd.Flags().StringVar(&CheckSubset, "read-data-subset", "",
+		"data subset to verify on check: number of subsets to rotate through (e.g. '7'), or fixed (e.g. '5%')")
+	scheduleCmd.Flags().BoolVar(&Diff, "diff", false, "compare each new snapshot with the previous snapshot")
+	scheduleCmd.Flags().StringVar(&RestoreTestCron, "restore-test", "",
+		"restore and verify the latest snapshot on schedule")
+	scheduleCmd.Flags().IntVar(&RestoreTest.Sample, "restore-test-sample", 0,
+		"number of random files to restore on restore test (defaults to the entire snapshot)")
+	scheduleCmd.Flags().StringVar(&RestoreTest.Manifest, "restore-test-manifest", "",
+		"checksum file to verify against on restore test instead of the live source")
+	scheduleCmd.Flags().StringVar(&RestoreTest.Dir, "restore-test-dir", "",
+		"directory to create the temporary restore directory in on restore test")
+	addStateDirOption(scheduleCmd)
+	scheduleCmd.Flags().DurationVar(&JobTimeout, "timeout", 0,
+		"maximum duration of each job, after which the job is interrupted (e.g. '6h')")
+	// bind timeout to environment variables
+	if err := viper.BindPFlag("timeout", scheduleCmd.Flags().Lookup("timeout")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind timeout flag")
+	}
+	scheduleCmd.Flags().DurationVar(&lib.KillGracePeriod, "grace-period", lib.KillGracePeriod,
+		"time to wait for an interrupted command to terminate before it is killed")
+	// bind grace period to environment variables
+	if err := viper.BindPFlag("grace_period", scheduleCmd.Flags().Lookup("grace-period")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind grace_period flag")
+	}
+	scheduleCmd.Flags().StringVar(&Catchup, "catchup", "none",
+		"policy for runs missed while not running: none, once, or all")
+	// bind catch-up policy to environment variables
+	if err := viper.BindPFlag("catchup", scheduleCmd.Flags().Lookup("catchup")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind catchup flag")
+	}
+	scheduleCmd.Flags().DurationVar(&DrainPeriod, "drain-period", 0,
+		"time to wait for a running job to finish when stopped, before the job is interrupted")
+	// bind drain period to environment variables
+	if err := viper.BindPFlag("drain_period", scheduleCmd.Flags().Lookup("drain-period")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind drain_period flag")
+	}
 	scheduleCmd.Flags().BoolVar(&Sustained, "sustained", false, "sustain processing of scheduled jobs despite errors")
+	scheduleCmd.Flags().StringVar(&Listen, "listen", "", "address of the HTTP status and control API (e.g. ':8080')")
+	// bind listen address to environment variables
+	if err := viper.BindPFlag("listen", scheduleCmd.Flags().Lookup("listen")); err != nil {
+		lib.Logger.Fatal().Err(err).Msg("Could not bind listen flag")
+	}
+
+	addStatusFileOption(scheduleCmd)
 
 	if err := addBackupOptions(scheduleCmd); err != nil {
 		lib.Logger.Fatal().Err(err).Msg("Could not init backup options")
 	}
 	addKeepOptions(scheduleCmd)
+	addPruneOptions(scheduleCmd)
+	addProfileOption(scheduleCmd)
 	rootCmd.AddCommand(scheduleCmd)
 }
 
+// initSchedule reads the settings of the scheduled jobs from the command line, config file, and environment. The cron
+// spec of the backup job is read from the config file ('cron') if not provided as argument. The flags matching
+// scheduleFlags and backupFlags can be set in the config file too, unless set on the command line.
+func initSchedule(cmd *cobra.Command, args []string) error {
+	BackupCron = viper.GetString("cron")
+	if len(args) > 0 {
+		BackupCron = args[0]
+	}
+	Host = viper.GetString("host")
+	StdinCommand = viper.GetString("stdin_command")
+	StdinFilename = viper.GetString("stdin_filename")
+	JobTimeout = viper.GetDuration("timeout")
+	Catchup = viper.GetString("catchup")
+	if err := initConfigFlags(cmd.Flags(), scheduleFlags); err != nil {
+		return err
+	}
+	if err := initStatusFile(cmd); err != nil {
+		return err
+	}
+	if err := initProfiles(cmd); err != nil {
+		return err
+	}
+	if err := initNotifications(); err != nil {
+		return err
+	}
+	if err := initHooks(); err != nil {
+		return err
+	}
+	if err := initDocker(); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("pings", &Pings); err != nil {
+		return fmt.Errorf("Could not read pings: %s", err.Error())
+	}
+	if err := viper.UnmarshalKey("timeouts", &JobTimeouts); err != nil {
+		return fmt.Errorf("Could not read timeouts: %s", err.Error())
+	}
+	if err := viper.UnmarshalKey("catchups", &Catchups); err != nil {
+		return fmt.Errorf("Could not read catch-up policies: %s", err.Error())
+	}
+	if err := viper.UnmarshalKey("backup_sets", &BackupSets); err != nil {
+		return fmt.Errorf("Could not read backup sets: %s", err.Error())
+	}
+	return validateScheduleFlags(cmd.Flags())
+}
+
+// newSchedule creates the restic manager and the schedule options as defined by the flags and config values read by
+// initSchedule.
+func newSchedule(cmd *cobra.Command) (*lib.ResticManager, lib.ScheduleOptions, error) {
+	var opts lib.ScheduleOptions
+	repositories, err := newRepositories()
+	if err != nil {
+		return nil, opts, err
+	}
+	catchup, catchups, err := catchupPolicies()
+	if err != nil {
+		return nil, opts, err
+	}
+	// only require the repository defined by the environment if targeted by a job
+	r := lib.NewResticManagerWithContext("restic", nil)
+	if usesDefaultRepository() {
+		if r, err = lib.NewResticManager(); err != nil {
+			return nil, opts, err
+		}
+	}
+	args, err := lib.ParseArgs(cmd.Flags(), "^keep-")
+	if err != nil {
+		return nil, opts, err
+	}
+	pruneArgs, err := lib.ParseArgs(cmd.Flags(), pruneFlags)
+	if err != nil {
+		return nil, opts, err
+	}
+	backupArgs, err := lib.ParseArgs(cmd.Flags(), backupFlags)
+	if err != nil {
+		return nil, opts, err
+	}
+	// restore tests verify the latest snapshot of the backup job
+	RestoreTest.Filter = lib.SnapshotFilter{Host: Host, Paths: BackupPaths}
+	opts = lib.ScheduleOptions{
+		BackupCron:      BackupCron,
+		ForgetCron:      ForgetCron,
+		CopyCron:        CopyCron,
+		CheckCron:       CheckCron,
+		CheckSubset:     CheckSubset,
+		RestoreTestCron: RestoreTestCron,
+		RestoreTest:     RestoreTest,
+		Diff:            Diff,
+		PruneCron:       PruneCron,
+		PruneFlags:      pruneArgs,
+		StateDir:        StateDir,
+		Paths:           BackupPaths,
+		BackupFlags:     backupArgs,
+		Init:            InitRepository,
+		Host:            Host,
+		Sustained:       Sustained,
+		KeepFlags:       args,
+		Listen:          Listen,
+		StatusFile:      StatusFile,
+		Notifications:   Notifications,
+		Pings:           Pings,
+		Sets:            BackupSets,
+		Repositories:    repositories,
+		Profiles:        Profiles,
+		Hooks:           BackupHooks,
+		Docker:          BackupDocker,
+		Stdin:           stdinSource(),
+		Timeout:         JobTimeout,
+		Timeouts:        JobTimeouts,
+		DrainPeriod:     DrainPeriod,
+		Catchup:         catchup,
+		Catchups:        catchups,
+	}
+	return r, opts, nil
+}
+
+// catchupPolicies converts Catchup and Catchups to typed catch-up policies. It returns an error if a policy is unknown.
+func catchupPolicies() (lib.CatchupPolicy, map[string]lib.CatchupPolicy, error) {
+	catchup, err := lib.ParseCatchupPolicy(Catchup)
+	if err != nil {
+		return catchup, nil, err
+	}
+	catchups := map[string]lib.CatchupPolicy{}
+	for tag, policy := range Catchups {
+		if catchups[tag], err = lib.ParseCatchupPolicy(policy); err != nil {
+			return catchup, nil, fmt.Errorf("Invalid catch-up policy for job '%s': %s", tag, err.Error())
+		}
+	}
+	return catchup, catchups, nil
+}
+
+// resetSchedule reverts the flags and config sections read by initSchedule to their defaults, unless the flags are set
+// on the command line. This ensures values removed from the config file do not persist on reload.
+func resetSchedule(flags *pflag.FlagSet) error {
+	var resetErr error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if resetErr != nil || !flag.Changed || cliFlags[flag.Name] {
+			return
+		}
+		if v, ok := flag.Value.(pflag.SliceValue); ok {
+			resetErr = v.Replace([]string{})
+		} else {
+			resetErr = flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	})
+	if !cliFlags["path"] {
+		BackupPaths = nil
+	}
+	// config sections are decoded into existing values, which may be referenced by the scheduled jobs
+	RepositoryProfiles, Notifications, Pings, JobTimeouts, Catchups, BackupSets = nil, nil, nil, nil, nil, nil
+	BackupHooks, BackupDocker = nil, nil
+	return resetErr
+}
+
+// reloadSchedule reads the config file again and rebuilds the scheduled jobs. Flags set on the command line take
+// precedence over the config file as before. The listen address, status file, state directory, notifications, grace
+// period, and drain period require a restart to take effect. The scheduler invokes reloadSchedule from a single
+// goroutine for both SIGHUP and config file changes, which is the only goroutine accessing viper once the scheduler
+// has started.
+func reloadSchedule(cmd *cobra.Command, args []string) ([]lib.Job, error) {
+	var notFound viper.ConfigFileNotFoundError
+	if err := viper.ReadInConfig(); err != nil && !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("Could not read config file: %s", err.Error())
+	}
+	if err := resetSchedule(cmd.Flags()); err != nil {
+		return nil, err
+	}
+	if err := initSchedule(cmd, args); err != nil {
+		return nil, err
+	}
+	r, opts, err := newSchedule(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return r.Jobs(opts)
+}
+
+// watchConfig invokes reload each time the config file changes, if a config file is used. The directory of the config
+// file is watched, as editors typically replace the file instead of writing to it. Unlike viper.WatchConfig, the
+// watcher does not read the config file itself, reload is expected to do so on the goroutine of the scheduler.
+func watchConfig(reload func()) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return
+	}
+	file = filepath.Clean(file)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		lib.Logger.Error().Err(err).Msg("Could not watch config file")
+		return
+	}
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		lib.Logger.Error().Err(err).Msg("Could not watch config file")
+		watcher.Close()
+		return
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) == file && e.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					lib.Logger.Info().Msgf("Config file '%s' has changed", e.Name)
+					reload()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				lib.Logger.Error().Err(err).Msg("Could not watch config file")
+			}
+		}
+	}()
+}
+
 // initScheduleFlags validates the provided persistent flags and initializes applicable global values. Currently
-// supported flag is "logformat". By default, logs are printed using pretty formatting, unless explicitly set to
-// another log format.
+// supported flags are "logformat", "listen", "grace-period", and "drain-period". By default, logs are printed using
+// pretty formatting, unless explicitly set to another log format. The listen address, grace period, and drain period
+// can be set as environment variable too.
 func initScheduleFlags(flags *pflag.FlagSet) {
 	if !viper.IsSet("logformat") {
 		lib.InitLogger(lib.LogFormat(lib.Pretty))
 	}
+	Listen = viper.GetString("listen")
+	lib.KillGracePeriod = viper.GetDuration("grace_period")
+	DrainPeriod = viper.GetDuration("drain_period")
 }
 
+// initNotifications reads the notifications from the config file. It returns an error if a notification is invalid.
+func initNotifications() error {
+	var configs []lib.NotificationConfig
+	if err := viper.UnmarshalKey("notifications", &configs); err != nil {
+		return fmt.Errorf("Could not read notifications: %s", err.Error())
+	}
+	notifications, err := lib.NewNotifications(configs)
+	if err != nil {
+		return err
+	}
+	Notifications = notifications
+	return nil
+}
+
+// usesDefaultRepository returns true if at least one scheduled job targets the repository defined by the environment
+// instead of a repository profile.
+func usesDefaultRepository() bool {
+	crons := BackupCron + ForgetCron + CopyCron + PruneCron + CheckCron + RestoreTestCron
+	if crons != "" && len(Profiles) == 0 {
+		return true
+	}
+	for _, set := range BackupSets {
+		if len(set.Profiles) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScheduleFlags validates the cron specs and backup path, unless backup sets are defined in the config file
+// instead. The backup sets are validated too.
 func validateScheduleFlags(flags *pflag.FlagSet) error {
-	if BackupPath == "" {
-		return errors.New("No backup path provided")
+	if BackupCron == "" && len(BackupSets) == 0 {
+		return errors.New("requires a cron argument")
+	}
+	if BackupCron != "" {
+		if err := initBackupSource(flags); err != nil {
+			return err
+		}
+	}
+	if JobTimeout < 0 || lib.KillGracePeriod < 0 || DrainPeriod < 0 {
+		return errors.New("timeout, grace period, and drain period cannot be negative")
+	}
+	for tag, timeout := range JobTimeouts {
+		if timeout < 0 {
+			return fmt.Errorf("Invalid timeout for job '%s': cannot be negative", tag)
+		}
+	}
+	if _, _, err := catchupPolicies(); err != nil {
+		return err
+	}
+	if err := lib.ValidateBackupSets(BackupSets); err != nil {
+		return err
+	}
+	for _, set := range BackupSets {
+		if _, err := lib.SelectProfiles(RepositoryProfiles, set.Profiles); err != nil {
+			return fmt.Errorf("Invalid backup set '%s': %s", set.Name, err.Error())
+		}
 	}
 
-	if ForgetCron != "" {
-		return lib.IsValidCron(ForgetCron)
+	for _, spec := range []string{BackupCron, ForgetCron, CopyCron, PruneCron, CheckCron, RestoreTestCron} {
+		if spec == "" {
+			continue
+		}
+		if err := lib.IsValidCron(spec); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -12,19 +12,44 @@ import (
 // Variables
 //======================================================================================================================
 
+// SnapshotFilter defines the host, tags, and paths to select snapshots by.
+var SnapshotFilter lib.SnapshotFilter
+
+// OutputFormat defines how to render the output of the snapshots command: table, json, or yaml.
+var OutputFormat string
+
 // snapshotsCmd represents the snapshots command
 var snapshotsCmd = &cobra.Command{
 	Use:   "snapshots",
 	Short: "List all snapshots",
 	Long: `
-The "snapshots" command lists all snapshots stored in the repository.`,
+The "snapshots" command lists all snapshots stored in the repository. The
+snapshots can be filtered by host, tag, and path. The output is rendered as a
+table by default, use "--output json" or "--output yaml" for machine-readable
+output.
+
+Examples:
+restic-unattended snapshots --host myhost --tag daily
+Lists all snapshots of host "myhost" tagged with "daily"
+
+restic-unattended snapshots --path /data/backup --output json
+Lists all snapshots of path "/data/backup" in JSON format
+`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := lib.ParseOutputFormat(OutputFormat)
+		return err
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		f := func() error {
 			r, err := lib.NewResticManager()
 			if err != nil {
 				return err
 			}
-			return r.Snapshots()
+			format, err := lib.ParseOutputFormat(OutputFormat)
+			if err != nil {
+				return err
+			}
+			return r.Snapshots(SnapshotFilter, format)
 		}
 		lib.HandleCmd(f, "Error retrieving snapshots", false)
 	},
@@ -34,7 +59,16 @@ The "snapshots" command lists all snapshots stored in the repository.`,
 // Private Functions
 //======================================================================================================================
 
-// init registers the snapshotsCmd with the rootCmd, which is managed by Cobra.
+// init registers the snapshotsCmd with the rootCmd, which is managed by Cobra. It defines several optional flags to
+// filter the snapshots and to specify the output format.
 func init() {
+	f := snapshotsCmd.Flags()
+	f.StringVarP(&SnapshotFilter.Host, "host", "H", "", "only consider snapshots for this host")
+	f.StringArrayVar(&SnapshotFilter.Tags, "tag", []string{},
+		"only consider snapshots which include this taglist (can be specified multiple times)")
+	f.StringArrayVar(&SnapshotFilter.Paths, "path", []string{},
+		"only consider snapshots which include this (absolute) path (can be specified multiple times)")
+	f.StringVarP(&OutputFormat, "output", "o", "table", "output format to use: table, json, yaml")
+	f.SortFlags = false
 	rootCmd.AddCommand(snapshotsCmd)
 }
@@ -0,0 +1,155 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// namePattern defines the allowed characters of the name of a backup set or repository profile, as the name is part
+// of the job tags.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// RetentionPolicy defines which snapshots to keep when forgetting old snapshots of a backup set. The fields correspond
+// to the keep-* flags of the forget command.
+type RetentionPolicy struct {
+	Last    int      `mapstructure:"last"`
+	Hourly  int      `mapstructure:"hourly"`
+	Daily   int      `mapstructure:"daily"`
+	Weekly  int      `mapstructure:"weekly"`
+	Monthly int      `mapstructure:"monthly"`
+	Yearly  int      `mapstructure:"yearly"`
+	Tags    []string `mapstructure:"tags"`
+	Within  string   `mapstructure:"within"`
+}
+
+// BackupSet defines a named set of paths to backup, including its own schedule and retention policy. Profiles
+// optionally defines the repository profiles to target, including 'all' to target all profiles. Repository optionally
+// overrides the repository location. Cron defines the schedule of the backup job, Forget defines the schedule of the
+// forget job that applies the retention policy. Hooks optionally defines the commands to run before and after each
+// backup of the set, Docker optionally defines the containers to stop during each backup. Stdin optionally defines a
+// command of which the output is backed up instead of the paths.
+type BackupSet struct {
+	Name       string          `mapstructure:"name"`
+	Paths      []string        `mapstructure:"paths"`
+	Excludes   []string        `mapstructure:"excludes"`
+	Tags       []string        `mapstructure:"tags"`
+	Host       string          `mapstructure:"host"`
+	Profiles   []string        `mapstructure:"profiles"`
+	Repository string          `mapstructure:"repository"`
+	Cron       string          `mapstructure:"cron"`
+	Forget     string          `mapstructure:"forget"`
+	Retention  RetentionPolicy `mapstructure:"retention"`
+	Hooks      *Hooks          `mapstructure:"hooks"`
+	Docker     *DockerOptions  `mapstructure:"docker"`
+	Stdin      *StdinSource    `mapstructure:"stdin"`
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// Args converts the retention policy to keep-* arguments of the restic forget command. Zero values are omitted.
+func (p RetentionPolicy) Args() []string {
+	var args []string
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"last", p.Last}, {"hourly", p.Hourly}, {"daily", p.Daily}, {"weekly", p.Weekly}, {"monthly", p.Monthly},
+		{"yearly", p.Yearly},
+	}
+	for _, c := range counts {
+		if c.value > 0 {
+			args = append(args, fmt.Sprintf("--keep-%s=%d", c.name, c.value))
+		}
+	}
+	for _, tag := range p.Tags {
+		args = append(args, "--keep-tag="+tag)
+	}
+	if p.Within != "" {
+		args = append(args, "--keep-within="+p.Within)
+	}
+	return args
+}
+
+// BackupOptions converts the backup set to the options of ResticManager.BackupWithOptions.
+func (s BackupSet) BackupOptions(init bool) BackupOptions {
+	return BackupOptions{Paths: s.Paths, Excludes: s.Excludes, Tags: s.Tags, Host: s.Host, Init: init, Hooks: s.Hooks,
+		Docker: s.Docker, Stdin: s.Stdin}
+}
+
+// ForgetArgs returns the arguments of the restic forget command for the backup set. The retention policy is limited
+// to the snapshots of the set, see SnapshotFilter.
+func (s BackupSet) ForgetArgs() []string {
+	return append(s.Retention.Args(), s.SnapshotFilter().args()...)
+}
+
+// SnapshotFilter returns the filter that selects the snapshots of the backup set, identified by its host, tags, and
+// paths. The path of a stdin set is the file name of the command output.
+func (s BackupSet) SnapshotFilter() SnapshotFilter {
+	filter := SnapshotFilter{Host: s.Host, Paths: s.Paths}
+	if len(s.Tags) > 0 {
+		filter.Tags = []string{strings.Join(s.Tags, ",")}
+	}
+	if s.Stdin != nil {
+		filter.Paths = []string{s.Stdin.path()}
+	}
+	return filter
+}
+
+// Validate returns an error if the backup set is incomplete or has an invalid schedule.
+func (s BackupSet) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("Invalid backup set name '%s'", s.Name)
+	}
+	if len(s.Paths) == 0 && s.Stdin == nil {
+		return fmt.Errorf("No paths provided for backup set '%s'", s.Name)
+	}
+	if err := s.Stdin.Validate(); err != nil {
+		return fmt.Errorf("Invalid stdin for backup set '%s': %s", s.Name, err.Error())
+	}
+	if s.Cron == "" {
+		return fmt.Errorf("No cron provided for backup set '%s'", s.Name)
+	}
+	if err := IsValidCron(s.Cron); err != nil {
+		return fmt.Errorf("Invalid cron for backup set '%s': %s", s.Name, err.Error())
+	}
+	if err := s.Hooks.Validate(); err != nil {
+		return fmt.Errorf("Invalid hooks for backup set '%s': %s", s.Name, err.Error())
+	}
+	if err := s.Docker.Validate(); err != nil {
+		return fmt.Errorf("Invalid Docker options for backup set '%s': %s", s.Name, err.Error())
+	}
+	if s.Forget != "" {
+		if err := IsValidCron(s.Forget); err != nil {
+			return fmt.Errorf("Invalid forget cron for backup set '%s': %s", s.Name, err.Error())
+		}
+		if len(s.Retention.Args()) == 0 {
+			return fmt.Errorf("No retention policy provided for backup set '%s'", s.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateBackupSets validates each backup set and ensures all names are unique.
+func ValidateBackupSets(sets []BackupSet) error {
+	names := map[string]bool{}
+	for _, s := range sets {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if names[s.Name] {
+			return fmt.Errorf("Duplicate backup set name '%s'", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestForgetArgs(t *testing.T) {
+	set := BackupSet{
+		Name:      "docs",
+		Paths:     []string{"/data/docs"},
+		Tags:      []string{"docs", "daily"},
+		Host:      "HOST",
+		Retention: RetentionPolicy{Last: 3, Daily: 7, Tags: []string{"keep"}, Within: "2y"},
+	}
+
+	got := strings.Join(set.ForgetArgs(), " ")
+	want := "--keep-last=3 --keep-daily=7 --keep-tag=keep --keep-within=2y --host=HOST --tag=docs,daily " +
+		"--path=/data/docs"
+	if got != want {
+		t.Errorf("ForgetArgs was incorrect, got: %s, want: %s.", got, want)
+	}
+
+	// a stdin set is identified by the file name of the command output
+	set.Stdin = &StdinSource{Command: "pg_dumpall", Filename: "db.sql"}
+	if got := set.ForgetArgs()[6]; got != "--path=/db.sql" {
+		t.Errorf("ForgetArgs was incorrect, got: %s, want: --path=/db.sql.", got)
+	}
+}
+
+func TestValidateBackupSets(t *testing.T) {
+	valid := BackupSet{Name: "docs", Paths: []string{"/data"}, Cron: "@daily"}
+	retention := RetentionPolicy{Daily: 7}
+
+	tables := []struct {
+		name    string
+		sets    []BackupSet
+		isValid bool
+	}{
+		{"valid", []BackupSet{valid}, true},
+		{"no sets", nil, true},
+		{"invalid name", []BackupSet{{Name: "my docs", Paths: []string{"/data"}, Cron: "@daily"}}, false},
+		{"no paths", []BackupSet{{Name: "docs", Cron: "@daily"}}, false},
+		{"no cron", []BackupSet{{Name: "docs", Paths: []string{"/data"}}}, false},
+		{"invalid cron", []BackupSet{{Name: "docs", Paths: []string{"/data"}, Cron: "* *"}}, false},
+		{"forget", []BackupSet{{Name: "docs", Paths: []string{"/data"}, Cron: "@daily", Forget: "@weekly",
+			Retention: retention}}, true},
+		{"forget without retention", []BackupSet{{Name: "docs", Paths: []string{"/data"}, Cron: "@daily",
+			Forget: "@weekly"}}, false},
+		{"duplicate", []BackupSet{valid, valid}, false},
+		{"stdin", []BackupSet{{Name: "db", Stdin: &StdinSource{Command: "pg_dumpall"}, Cron: "@daily"}}, true},
+		{"stdin without command", []BackupSet{{Name: "db", Stdin: &StdinSource{}, Cron: "@daily"}}, false},
+	}
+
+	for _, table := range tables {
+		err := ValidateBackupSets(table.sets)
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("ValidateBackupSets '%s' was incorrect, got: %t, want: %t.", table.name, isValid, table.isValid)
+		}
+	}
+}
+
+func TestWithRepository(t *testing.T) {
+	r := NewResticManagerWithContext("restic", []string{"RESTIC_REPOSITORY=default", "RESTIC_PASSWORD=secret"})
+	if r.WithRepository("") != r {
+		t.Errorf("WithRepository returned a new manager for an empty repository")
+	}
+
+	got := r.WithRepository("s3:bucket").env
+	want := []string{"RESTIC_PASSWORD=secret", "RESTIC_REPOSITORY=s3:bucket"}
+	if !Equal(got, want) {
+		t.Errorf("WithRepository returned incorrect environment, got: %v, want: %v.", got, want)
+	}
+}
@@ -4,25 +4,99 @@
 package lib
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
-// Job defines a single cron job with a cron specification and callback function. The Counter tracks the number of time
-// the job has been triggered. The limit defines the maximum number of runs, where 0 means infinite.
+// Job defines a single cron job with a cron specification and callback function. RunS is an alternative callback
+// function for jobs that produce a backup summary, it takes precedence over RunE if set. The Counter tracks the
+// number of time the job has been triggered. The limit defines the maximum number of runs, where 0 means infinite.
+// Ping optionally defines the URLs of a monitoring service to ping when the job starts, succeeds, or fails. The
+// callback functions receive a context that is canceled when the job exceeds its Timeout (if non-zero) or when the
+// scheduler is interrupted. Catchup defines how runs missed while the scheduler was not running are handled.
 type Job struct {
 	id      cron.EntryID
+	state   *jobState
 	Tag     string
 	Spec    string
-	RunE    func() error
+	RunE    func(ctx context.Context) error
+	RunS    func(ctx context.Context) (*BackupSummary, error)
 	Counter int
 	Limit   int
+	Ping    *PingConfig
+	Timeout time.Duration
+	Catchup CatchupPolicy
 }
 
+// JobStatus reports the state of a scheduled job, including the outcome of its most recent run. The next run time is
+// derived from the cron scheduler and is omitted if the job is no longer scheduled.
+type JobStatus struct {
+	Tag         string         `json:"tag"`
+	Spec        string         `json:"spec"`
+	Runs        int            `json:"runs"`
+	Limit       int            `json:"limit,omitempty"`
+	Paused      bool           `json:"paused"`
+	Running     bool           `json:"running"`
+	Next        *time.Time     `json:"next,omitempty"`
+	Prev        *time.Time     `json:"prev,omitempty"`
+	LastStart   *time.Time     `json:"last_start,omitempty"`
+	LastEnd     *time.Time     `json:"last_end,omitempty"`
+	LastSuccess *time.Time     `json:"last_success,omitempty"`
+	LastResult  string         `json:"last_result,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	LastSummary *BackupSummary `json:"last_summary,omitempty"`
+}
+
+// SchedulerStatus reports the state of the scheduler, including the number of jobs waiting to be processed and the
+// status of each scheduled job.
+type SchedulerStatus struct {
+	Started       time.Time   `json:"started"`
+	Updated       time.Time   `json:"updated"`
+	QueueDepth    int         `json:"queue_depth"`
+	QueueCapacity int         `json:"queue_capacity"`
+	Jobs          []JobStatus `json:"jobs"`
+}
+
+// CronOptions defines the settings of the cron scheduler. HaltOnError stops processing of all jobs if a job returns an
+// error. Listen defines the address of the HTTP status and control API, which is disabled if Listen is empty.
+// StatusFile defines the path of a file that receives the scheduler status after each job, which is disabled if
+// StatusFile is empty. Notifications are sent when a job finishes or is dropped. DrainPeriod defines how long a
+// running job may take to finish once the scheduler receives a termination signal, the job is interrupted immediately
+// if zero. Reload optionally rebuilds the jobs when the scheduler receives SIGHUP, see scheduler.update. Watch is
+// invoked once with a callback that triggers a reload too, e.g. to reload the jobs when a config file changes. History
+// optionally records the outcome of each job run.
+type CronOptions struct {
+	HaltOnError   bool
+	Listen        string
+	StatusFile    string
+	Notifications []*Notification
+	DrainPeriod   time.Duration
+	Reload        func() ([]Job, error)
+	Watch         func(reload func())
+	History       *HistoryStore
+}
+
+// CatchupPolicy defines how a job handles the runs it missed while the scheduler was not running.
+type CatchupPolicy int
+
+// Defines a pseudo enumeration of possible catch-up policies.
+const (
+	// CatchupNone skips missed runs, which is the default behavior of cron.
+	CatchupNone CatchupPolicy = iota
+	// CatchupOnce runs a job once if it missed one or more runs, similar to anacron.
+	CatchupOnce
+	// CatchupAll runs a job for each missed run, up to the capacity of the job channel.
+	CatchupAll
+)
+
 // Result represents a typed goroutine result.
 type Result int
 
@@ -36,6 +110,40 @@ const (
 	Fatal
 )
 
+// jobCapacity defines the maximum number of jobs waiting to be processed, additional jobs are dropped.
+const jobCapacity = 5
+
+// jobState captures the runtime state of a scheduled job. It is shared by all copies of a job.
+type jobState struct {
+	paused      bool
+	running     bool
+	hasRun      bool
+	lastStart   time.Time
+	lastEnd     time.Time
+	lastSuccess time.Time
+	result      Result
+	err         error
+	summary     *BackupSummary
+}
+
+// scheduler manages the cron scheduler and the worker processing the released jobs. The context is canceled when the
+// scheduler is interrupted, which interrupts the running job too.
+type scheduler struct {
+	mu            sync.Mutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+	cron          *cron.Cron
+	jobs          []*Job
+	jobChan       chan Job
+	sigChan       chan os.Signal
+	metrics       *metrics
+	notifications []*Notification
+	started       time.Time
+	statusFile    string
+	history       *HistoryStore
+	haltOnError   bool
+}
+
 type workerResult struct {
 	result Result
 	err    error
@@ -45,14 +153,424 @@ type workerResult struct {
 // Private Functions
 //======================================================================================================================
 
-// worker processes jobs available on the provided jobChan one at a time. The function runs indefinitely, unless
-// interrupted (a signal becomes available on the sigChan). The result channel captures the reason for the worker being
-// stopped, if haltOnError is set to true.
-func worker(jobChan <-chan Job, sigChan <-chan os.Signal, result chan workerResult, haltOnError bool) {
+// cronParser generates a parser for cron schedules. It supports optional seconds next to the commonly supported cron
+// fields.
+func cronParser() cron.Parser {
+	return cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow |
+		cron.Descriptor)
+}
+
+// newScheduler creates a scheduler and registers the provided jobs with the cron scheduler. Jobs with an invalid cron
+// specification are skipped. The cron scheduler is not started yet.
+func newScheduler(jobs []Job, opts CronOptions) *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &scheduler{
+		ctx:           ctx,
+		cancel:        cancel,
+		cron:          cron.New(cron.WithParser(cronParser())),
+		jobChan:       make(chan Job, jobCapacity),
+		sigChan:       make(chan os.Signal, 1),
+		metrics:       newMetrics(),
+		notifications: opts.Notifications,
+		started:       time.Now(),
+		statusFile:    opts.StatusFile,
+		history:       opts.History,
+		haltOnError:   opts.HaltOnError,
+	}
+
+	s.update(jobs)
+	return s
+}
+
+// update registers the provided jobs with the cron scheduler, replacing the jobs scheduled before. Jobs are matched by
+// tag: new jobs are scheduled, jobs that are no longer provided are removed, and the definition of existing jobs is
+// updated while retaining their state and run count. Existing jobs are rescheduled if their cron specification has
+// changed. A job that is running or queued completes using its previous definition. Jobs with an invalid cron
+// specification are skipped.
+func (s *scheduler) update(jobs []Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := map[string]*Job{}
+	for _, job := range s.jobs {
+		current[job.Tag] = job
+	}
+
+	updated := []*Job{}
+	for _, j := range jobs {
+		job, ok := current[j.Tag]
+		if ok {
+			delete(current, j.Tag)
+			spec := job.Spec
+			job.Spec, job.RunE, job.RunS, job.Limit, job.Ping, job.Timeout = j.Spec, j.RunE, j.RunS, j.Limit, j.Ping,
+				j.Timeout
+			job.Catchup = j.Catchup
+			if spec == job.Spec {
+				updated = append(updated, job)
+				continue
+			}
+			s.cron.Remove(job.id)
+			Logger.Info().Msgf("Rescheduling job '%s' with cron spec '%s'", job.Tag, job.Spec)
+		} else {
+			// copy job value to avoid reuse of loop variables across goroutines
+			// see: https://golang.org/doc/effective_go.html?h=panic#channels
+			job = &Job{}
+			*job = j
+			job.state = &jobState{}
+			Logger.Info().Msgf("Scheduling job '%s' with cron spec '%s'", job.Tag, job.Spec)
+		}
+
+		id, err := s.cron.AddFunc(job.Spec, s.release(job))
+		if err != nil {
+			Logger.Error().Msgf("Could not schedule job '%s'", job.Tag)
+			continue
+		}
+		job.id = id
+		updated = append(updated, job)
+		s.metrics.mu.Lock()
+		s.metrics.register(job.Tag)
+		s.metrics.mu.Unlock()
+		entry := s.cron.Entry(id)
+		t := entry.Schedule.Next(time.Now()).Format(time.RFC3339)
+		Logger.Info().Msgf("First '%s' job scheduled to run at '%s'", job.Tag, t)
+	}
+
+	for _, job := range current {
+		Logger.Info().Msgf("Removing job '%s'", job.Tag)
+		s.cron.Remove(job.id)
+	}
+	s.jobs = updated
+}
+
+// list returns the scheduled jobs.
+func (s *scheduler) list() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs
+}
+
+// enqueue puts a job in the job channel unless it is full. It returns false if the job has been dropped.
+func (s *scheduler) enqueue(job Job) bool {
+	select {
+	case s.jobChan <- job:
+		Logger.Debug().Msgf("Added new job '%s' to channel", job.Tag)
+		return true
+	default:
+		Logger.Error().Msgf("Dropped job '%s' (channel is full)", job.Tag)
+		s.metrics.drop(job.Tag)
+		s.notify(NewJobEvent(job.Tag, OutcomeDropped, time.Time{}, time.Time{}, nil, nil))
+		return false
+	}
+}
+
+// find returns the scheduled job identified by tag, or nil if no such job exists.
+func (s *scheduler) find(tag string) *Job {
+	for _, job := range s.list() {
+		if job.Tag == tag {
+			return job
+		}
+	}
+	return nil
+}
+
+// process runs a single job, records its outcome in the job state, metrics, and history, and sends any notifications.
+// The result is Done if the job succeeded, Fatal if the job returned a fatal lib.ResticError, or Error otherwise. A job
+// that exceeds its timeout or is interrupted results in an Error.
+func (s *scheduler) process(job Job) (Result, error) {
+	start := time.Now()
+	s.mu.Lock()
+	job.state.running = true
+	job.state.lastStart = start
+	s.mu.Unlock()
+
+	ctx, cancel := s.ctx, context.CancelFunc(func() {})
+	if job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+	}
+	summary, err := job.run(ctx)
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		err = &ResticError{Err: fmt.Sprintf("Job '%s' exceeded timeout of %s", job.Tag, job.Timeout), Fatal: false}
+	case context.Canceled:
+		err = &ResticError{Err: fmt.Sprintf("Job '%s' interrupted", job.Tag), Fatal: false}
+	}
+	cancel()
+
+	result := Result(Done)
+	if err != nil {
+		var resticError *ResticError
+		if errors.As(err, &resticError) && resticError.Fatal {
+			result = Result(Fatal)
+		} else {
+			result = Result(Error)
+		}
+	}
+
+	end := time.Now()
+	s.mu.Lock()
+	job.state.running = false
+	job.state.hasRun = true
+	job.state.lastEnd = end
+	job.state.result = result
+	job.state.err = err
+	job.state.summary = summary
+	if result == Result(Done) {
+		job.state.lastSuccess = job.state.lastEnd
+	}
+	s.mu.Unlock()
+
+	s.metrics.observe(job.Tag, result, end.Sub(start), summary)
+	s.writeStatus()
+	if s.history != nil {
+		if herr := s.history.Append(NewHistoryRecord(job.Tag, result, start, end, err, summary)); herr != nil {
+			Logger.Error().Err(herr).Msg("Could not append job to history")
+		}
+	}
+	s.notify(NewJobEvent(job.Tag, result.Outcome(), start, end, err, summary))
+	return result, err
+}
+
+// run invokes the callback function of the job with ctx, wrapped by the configured pings if any. It returns the backup
+// summary if the job defines RunS, or nil otherwise.
+func (j Job) run(ctx context.Context) (*BackupSummary, error) {
+	run := func() (*BackupSummary, error) {
+		if j.RunS != nil {
+			return j.RunS(ctx)
+		}
+		return nil, j.RunE(ctx)
+	}
+	if j.Ping != nil {
+		run = j.Ping.wrap(j.Tag, run)
+	}
+	return run()
+}
+
+// release returns the callback invoked by the cron scheduler for a specific job. The job is added to the job channel,
+// unless it is paused or has reached its limit. The scheduler is stopped when all jobs have reached their limit.
+func (s *scheduler) release(job *Job) func() {
+	return func() {
+		s.mu.Lock()
+		if job.state.paused {
+			tag := job.Tag
+			s.mu.Unlock()
+			Logger.Info().Msgf("Skipped job '%s' (job is paused)", tag)
+			return
+		}
+		job.Counter++
+		released := *job
+		s.mu.Unlock()
+
+		// process job if it has not reached it's limit; use the copy taken under lock, as reloads may update job
+		if released.Limit == 0 || released.Counter <= released.Limit {
+			s.enqueue(released)
+		} else {
+			// remove the job from the scheduler and stop the scheduler when all jobs are done
+			Logger.Debug().Msgf("Stopped job '%s', limit %d is reached", released.Tag, released.Limit)
+			s.cron.Remove(released.id)
+			if len(s.cron.Entries()) == 0 {
+				s.sigChan <- syscall.SIGSTOP
+			}
+		}
+	}
+}
+
+// status returns the status of a scheduled job.
+func (s *scheduler) status(job *Job) JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timeOrNil := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	status := JobStatus{
+		Tag:         job.Tag,
+		Spec:        job.Spec,
+		Runs:        job.Counter,
+		Limit:       job.Limit,
+		Paused:      job.state.paused,
+		Running:     job.state.running,
+		LastStart:   timeOrNil(job.state.lastStart),
+		LastEnd:     timeOrNil(job.state.lastEnd),
+		LastSuccess: timeOrNil(job.state.lastSuccess),
+	}
+
+	// derive the next run time from the cron entry, the entry is invalid if the job has been removed
+	if entry := s.cron.Entry(job.id); entry.Valid() {
+		next := entry.Next
+		if next.IsZero() {
+			next = entry.Schedule.Next(time.Now())
+		}
+		status.Next = timeOrNil(next)
+		status.Prev = timeOrNil(entry.Prev)
+	}
+
+	if job.state.hasRun {
+		status.LastResult = job.state.result.String()
+		if job.state.err != nil {
+			status.LastError = job.state.err.Error()
+		}
+		status.LastSummary = job.state.summary
+	}
+
+	return status
+}
+
+// running returns the job that is currently running, or nil if no job is running.
+func (s *scheduler) running() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.state.running {
+			return job
+		}
+	}
+	return nil
+}
+
+// logState logs the final state of each job.
+func (s *scheduler) logState() {
+	for _, job := range s.list() {
+		status := s.status(job)
+		event := Logger.Info().Str("tag", status.Tag).Int("runs", status.Runs)
+		if status.LastResult != "" {
+			event = event.Str("last_result", status.LastResult).Time("last_end", *status.LastEnd)
+		}
+		if status.LastError != "" {
+			event = event.Str("last_error", status.LastError)
+		}
+		event.Msg("Final job state")
+	}
+}
+
+// schedulerStatus returns the status of the scheduler and all its jobs.
+func (s *scheduler) schedulerStatus() SchedulerStatus {
+	status := SchedulerStatus{
+		Started:       s.started,
+		Updated:       time.Now(),
+		QueueDepth:    len(s.jobChan),
+		QueueCapacity: cap(s.jobChan),
+		Jobs:          []JobStatus{},
+	}
+	for _, job := range s.list() {
+		status.Jobs = append(status.Jobs, s.status(job))
+	}
+	return status
+}
+
+// terminate handles the first termination signal received on signals, unless done is closed before. It stops the cron
+// scheduler and instructs the worker to stop once the running job, if any, has finished. The running job may take up
+// to drain to finish, after which its context is canceled. A second signal cancels the running job immediately.
+func (s *scheduler) terminate(signals <-chan os.Signal, drain time.Duration, done <-chan struct{}) {
+	var sig os.Signal
+	select {
+	case sig = <-signals:
+	case <-done:
+		return
+	}
+	Logger.Warn().Msgf("Received signal '%s', stopping scheduler", sig)
+	s.cron.Stop()
+	select {
+	case s.sigChan <- sig:
+	default:
+		// the worker is stopping already
+	}
+
+	if job := s.running(); job != nil && drain > 0 {
+		Logger.Warn().Msgf("Waiting up to %s for job '%s' to finish", drain, job.Tag)
+		timer := time.NewTimer(drain)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			Logger.Warn().Msgf("Job '%s' did not finish within %s", job.Tag, drain)
+		case sig = <-signals:
+			Logger.Warn().Msgf("Received signal '%s', interrupting job '%s'", sig, job.Tag)
+		case <-done:
+			return
+		}
+	}
+	s.cancel()
+}
+
+// catchUp releases the jobs that missed one or more runs since their last run in the history, e.g. because the process
+// or host was down at the scheduled time. Depending on the catch-up policy of a job, a missed run is released once, or
+// each missed run is released up to the capacity of the job channel. Jobs without a previous run in the history are
+// skipped, as their missed runs cannot be determined.
+func (s *scheduler) catchUp(now time.Time) {
+	if s.history == nil {
+		return
+	}
+	last, err := s.history.Last()
+	if err != nil {
+		Logger.Error().Err(err).Msg("Could not read job history, skipping catch-up of missed runs")
+		return
+	}
+
+	parser := cronParser()
+	for _, job := range s.list() {
+		// copy the fields under lock, as a reload may update the job concurrently
+		s.mu.Lock()
+		tag, spec, policy := job.Tag, job.Spec, job.Catchup
+		s.mu.Unlock()
+
+		record, ok := last[tag]
+		if policy == CatchupPolicy(CatchupNone) || !ok {
+			continue
+		}
+		schedule, err := parser.Parse(spec)
+		if err != nil {
+			continue
+		}
+
+		missed := 0
+		for t := schedule.Next(record.Start); !t.After(now) && missed < jobCapacity; t = schedule.Next(t) {
+			missed++
+		}
+		if missed == 0 {
+			continue
+		}
+		if policy == CatchupPolicy(CatchupOnce) {
+			missed = 1
+		}
+		Logger.Info().Msgf("Catching up %d missed run(s) of job '%s' since '%s'", missed, tag,
+			record.Start.Format(time.RFC3339))
+		for i := 0; i < missed; i++ {
+			s.release(job)()
+		}
+	}
+}
+
+// watch reloads the jobs each time a signal is received on reloads, until done is closed. The current jobs remain
+// scheduled if the jobs cannot be reloaded.
+func (s *scheduler) watch(reloads <-chan os.Signal, reload func() ([]Job, error), done <-chan struct{}) {
+	for {
+		select {
+		case <-reloads:
+			Logger.Info().Msg("Reloading jobs")
+			jobs, err := reload()
+			if err != nil {
+				Logger.Error().Err(err).Msg("Could not reload jobs, keeping current jobs")
+				continue
+			}
+			s.update(jobs)
+			s.writeStatus()
+		case <-done:
+			return
+		}
+	}
+}
+
+// worker processes jobs available on the job channel one at a time. The function runs indefinitely, unless
+// interrupted (a signal becomes available on the signal channel). The result channel captures the reason for the
+// worker being stopped, if haltOnError is set to true.
+func (s *scheduler) worker(result chan workerResult) {
 	// wait for an interrupt or new available job; split into two selects to prioritize interrupts over new jobs
 	for {
 		select {
-		case sig := <-sigChan:
+		case sig := <-s.sigChan:
 			var r workerResult
 			if sig == syscall.SIGSTOP {
 				Logger.Warn().Msg("Worker processing stopped")
@@ -68,7 +586,7 @@ func worker(jobChan <-chan Job, sigChan <-chan os.Signal, result chan workerResu
 		}
 
 		select {
-		case job := <-jobChan:
+		case job := <-s.jobChan:
 			Logger.Debug().Msgf("Worker '%s' started processing new job", job.Tag)
 			if job.Limit > 0 {
 				Logger.Debug().Msgf("Worker '%s' on run %d with limit %d", job.Tag, job.Counter, job.Limit)
@@ -80,11 +598,12 @@ func worker(jobChan <-chan Job, sigChan <-chan os.Signal, result chan workerResu
 				result <- r
 				return
 			}
-			if err := job.RunE(); err != nil {
+			if res, err := s.process(job); err != nil {
 				Logger.Error().Err(err).Msgf("Could not process worker '%s'", job.Tag)
-				if haltOnError {
+				// an interrupted job is reported by the signal channel instead
+				if s.haltOnError && s.ctx.Err() == nil {
 					var r workerResult
-					r.result = Result(Error)
+					r.result = res
 					r.err = err
 					result <- r
 					return
@@ -98,13 +617,6 @@ func worker(jobChan <-chan Job, sigChan <-chan os.Signal, result chan workerResu
 	}
 }
 
-// cronParser generates a parser for cron schedules. It supports optional seconds next to the commonly supported cron
-// fields.
-func cronParser() cron.Parser {
-	return cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow |
-		cron.Descriptor)
-}
-
 //======================================================================================================================
 // Public Functions
 //======================================================================================================================
@@ -125,73 +637,76 @@ func RunCron(job Job, haltOnError bool) error {
 	return RunCronJobs([]Job{job}, haltOnError)
 }
 
-// RunCronJobs schedules one or more jobs according to a cron specification. The specification supports default cron
-// expressions, as well as optional seconds. See https://pkg.go.dev/gopkg.in/robfig/cron.v3 for additional
-// information. The cron jobs runs indefinitely, unless interrupted (e.g. pressing Ctrl-C or sending SIGINT). Use the
-// the callback function cmd of each job to execute a specific command at the defined interval.
+// RunCronJobs schedules one or more jobs according to a cron specification. It is a wrapper for
+// RunCronJobsWithOptions using default options.
+func RunCronJobs(jobs []Job, haltOnError bool) error {
+	return RunCronJobsWithOptions(jobs, CronOptions{HaltOnError: haltOnError})
+}
+
+// RunCronJobsWithOptions schedules one or more jobs according to a cron specification. The specification supports
+// default cron expressions, as well as optional seconds. See https://pkg.go.dev/gopkg.in/robfig/cron.v3 for additional
+// information. The cron jobs runs indefinitely, unless terminated by SIGINT (e.g. pressing Ctrl-C), SIGTERM (e.g.
+// stopping a container), or SIGHUP. SIGHUP reloads the jobs instead if a reload function is provided, jobs that are
+// running continue uninterrupted. A running job may take up to the configured drain period to finish once terminated,
+// see scheduler.terminate. The final state of the jobs is logged and written to the status file before returning. Use
+// the the callback function cmd of each job to execute a specific command at the defined interval.
 //
 // Jobs run one at a time and are delayed if the previous job is still running. As the cron package does not support
 // chaining across different jobs, all cron job are processed by a single worker routine using a dedicated job channel.
 // Jobs are added to this channel once they are released by the cron scheduler. The channel has a maximum capacity of 5
-// jobs, additional jobs are dropped. The worker routine supports graceful termination.
-func RunCronJobs(jobs []Job, haltOnError bool) error {
-	// capture interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-
+// jobs, additional jobs are dropped. The worker routine supports graceful termination. If a listen address is
+// provided, the status of the jobs is exposed by an HTTP API, see scheduler.handler for more details. If a status
+// file is provided, the status is written to this file on start and after each job, see CheckHealth for more details.
+func RunCronJobsWithOptions(jobs []Job, opts CronOptions) error {
 	// setup cron processing, delaying execution if a previous job is still running
-	// jobs are dropped when a backlog of 5 items is reached
-	jobChan := make(chan Job, 5)
-	cron := cron.New(cron.WithParser(cronParser()))
-	for _, j := range jobs {
-		// copy job value to avoid reuse of loop variables across goroutines
-		// see: https://golang.org/doc/effective_go.html?h=panic#channels
-		job := j
-
-		Logger.Info().Msgf("Scheduling job '%s' with cron spec '%s'", job.Tag, job.Spec)
-		wrapper := func() {
-			job.Counter++
-			// process job if it has not reached it's limit
-			if job.Limit == 0 || job.Counter <= job.Limit {
-				// put job in the channel unless it is full
+	s := newScheduler(jobs, opts)
+
+	// capture termination signals, draining or canceling the running job and stopping the worker
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	defer close(done)
+	if opts.Reload != nil {
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		reloads := make(chan os.Signal, 1)
+		signal.Notify(reloads, syscall.SIGHUP)
+		defer signal.Stop(reloads)
+		go s.watch(reloads, opts.Reload, done)
+		if opts.Watch != nil {
+			// coalesce reloads triggered while a reload is pending
+			opts.Watch(func() {
 				select {
-				case jobChan <- job:
-					Logger.Debug().Msgf("Added new job '%s' to channel", job.Tag)
+				case reloads <- syscall.SIGHUP:
 				default:
-					Logger.Error().Msgf("Dropped job '%s' (channel is full)", job.Tag)
 				}
-			} else {
-				// remove the job from the scheduler and stop the scheduler when all jobs are done
-				Logger.Debug().Msgf("Stopped job '%s', limit %d is reached", job.Tag, job.Limit)
-				cron.Remove(job.id)
-				if len(cron.Entries()) == 0 {
-					sigChan <- syscall.SIGSTOP
-				}
-			}
-		}
-		id, err := cron.AddFunc(job.Spec, wrapper)
-		if err != nil {
-			Logger.Error().Msgf("Could not schedule job '%s'", job.Tag)
-		} else {
-			job.id = id
-			entry := cron.Entry(id)
-			t := entry.Schedule.Next(time.Now()).Format(time.RFC3339)
-			Logger.Info().Msgf("First '%s' job scheduled to run at '%s'", job.Tag, t)
+			})
 		}
+	} else {
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	}
+	go s.terminate(signals, opts.DrainPeriod, done)
+
+	// start the HTTP status and control API if instructed
+	if opts.Listen != "" {
+		srv := s.serve(opts.Listen)
+		defer shutdownServer(srv)
 	}
 
 	// setup a deferred clean-up function
 	defer func() {
-		cron.Stop()
-		signal.Stop(sigChan)
-		close(jobChan)
+		s.cron.Stop()
+		signal.Stop(signals)
+		s.cancel()
+		s.logState()
+		s.writeStatus()
 		Logger.Debug().Msg("Exiting lib.RunCronJobs()")
 	}()
 
-	// start the worker and cron scheduler
+	// start the worker and cron scheduler, releasing any missed runs first
+	s.writeStatus()
 	result := make(chan workerResult)
-	go worker(jobChan, sigChan, result, haltOnError)
-	cron.Start()
+	go s.worker(result)
+	s.catchUp(time.Now())
+	s.cron.Start()
 
 	// wait for the worker and terminate on error
 	r := <-result
@@ -200,12 +715,48 @@ func RunCronJobs(jobs []Job, haltOnError bool) error {
 		Logger.Info().Msgf("Cron processing stopped")
 		return nil
 	case Result(Interrupted):
-		return &ResticError{"Cron processing interrupted", false}
+		return &ResticError{Err: "Cron processing interrupted", Fatal: false}
 	case Result(Error):
-		return &ResticError{"Error processing cron jobs", false}
+		return &ResticError{Err: "Error processing cron jobs", Fatal: false}
 	case Result(Fatal):
-		return &ResticError{"Error processing cron jobs", true}
+		return &ResticError{Err: "Error processing cron jobs", Fatal: true}
 	default:
 		return nil
 	}
 }
+
+// Outcome converts a typed result to the outcome of a job as used by notifications: success, failure, or fatal.
+func (r Result) Outcome() string {
+	switch r {
+	case Result(Done):
+		return OutcomeSuccess
+	case Result(Fatal):
+		return OutcomeFatal
+	default:
+		return OutcomeFailure
+	}
+}
+
+// ParseCatchupPolicy converts a policy string into a typed catch-up policy: none, once, or all. It returns an error if
+// the input string does not match known values.
+func ParseCatchupPolicy(policy string) (CatchupPolicy, error) {
+	switch policy {
+	case "none", "":
+		return CatchupPolicy(CatchupNone), nil
+	case "once":
+		return CatchupPolicy(CatchupOnce), nil
+	case "all":
+		return CatchupPolicy(CatchupAll), nil
+	}
+	return CatchupPolicy(CatchupNone), fmt.Errorf("Unknown catch-up policy: '%s'", policy)
+}
+
+// String converts a typed catch-up policy to it's string representation.
+func (p CatchupPolicy) String() string {
+	return [...]string{"none", "once", "all"}[p]
+}
+
+// String converts a typed result to it's string representation.
+func (r Result) String() string {
+	return [...]string{"done", "stopped", "interrupted", "error", "fatal"}[r]
+}
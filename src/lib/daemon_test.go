@@ -4,8 +4,13 @@
 package lib
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -71,7 +76,7 @@ func TestRunCronJobs(t *testing.T) {
 	test1.Tag = "test 1"
 	test1.Spec = "0/2 * * * * *"
 	test1.Limit = 2
-	test1.RunE = func() error {
+	test1.RunE = func(ctx context.Context) error {
 		logs = append(logs, fmt.Sprintf("Job '%s' has fired", test1.Tag))
 		return nil
 	}
@@ -82,7 +87,7 @@ func TestRunCronJobs(t *testing.T) {
 	test2.Tag = "test 2"
 	test2.Spec = "1/2 * * * * *"
 	test2.Limit = 2
-	test2.RunE = func() error {
+	test2.RunE = func(ctx context.Context) error {
 		logs = append(logs, fmt.Sprintf("Job '%s' has fired", test2.Tag))
 		return nil
 	}
@@ -96,3 +101,199 @@ func TestRunCronJobs(t *testing.T) {
 		t.Errorf("RunCronJobs failed")
 	}
 }
+
+func TestJobTimeout(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	var job Job
+	job.Tag = "test"
+	job.Spec = "@every 1h"
+	job.Timeout = 10 * time.Millisecond
+	job.RunE = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s := newScheduler([]Job{job}, CronOptions{})
+	result, err := s.process(*s.jobs[0])
+	if result != Result(Error) || err == nil || !strings.Contains(err.Error(), "exceeded timeout") {
+		t.Errorf("process did not interrupt job after timeout, got: %v, %v.", result, err)
+	}
+
+	s.cancel()
+	result, err = s.process(*s.jobs[0])
+	if result != Result(Error) || err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("process did not interrupt canceled job, got: %v, %v.", result, err)
+	}
+}
+
+func TestDrainJob(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	tests := []struct {
+		name   string
+		drain  time.Duration
+		delay  time.Duration
+		result Result
+	}{
+		{"finished", time.Second, 50 * time.Millisecond, Result(Done)},
+		{"interrupted", 50 * time.Millisecond, 10 * time.Second, Result(Error)},
+	}
+	for _, tt := range tests {
+		signals := make(chan os.Signal, 1)
+		done := make(chan struct{})
+
+		// send the termination signal once the job is running
+		var job Job
+		job.Tag = "test"
+		job.Spec = "@every 1h"
+		job.RunE = func(ctx context.Context) error {
+			signals <- syscall.SIGTERM
+			select {
+			case <-time.After(tt.delay):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		s := newScheduler([]Job{job}, CronOptions{})
+		go s.terminate(signals, tt.drain, done)
+		result, _ := s.process(*s.jobs[0])
+		close(done)
+		if result != tt.result {
+			t.Errorf("terminate returned incorrect result for %s job, got: %s, want: %s.", tt.name, result, tt.result)
+		}
+		if sig := <-s.sigChan; sig != syscall.SIGTERM {
+			t.Errorf("terminate did not stop the worker, got: %s.", sig)
+		}
+	}
+}
+
+func TestUpdateJobs(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	run := func(ctx context.Context) error { return nil }
+	jobs := []Job{
+		{Tag: "backup", Spec: "@daily", RunE: run},
+		{Tag: "forget", Spec: "@weekly", RunE: run},
+	}
+	s := newScheduler(jobs, CronOptions{})
+	backup := s.find("backup")
+	backup.state.lastSuccess = time.Now()
+	backupID := backup.id
+	forgetID := s.find("forget").id
+
+	// reschedule backup, remove forget, and add check
+	s.update([]Job{
+		{Tag: "backup", Spec: "@hourly", RunE: run},
+		{Tag: "check", Spec: "@monthly", RunE: run},
+	})
+
+	if len(s.jobs) != 2 || len(s.cron.Entries()) != 2 {
+		t.Fatalf("update returned incorrect number of jobs, got: %d, want: 2.", len(s.jobs))
+	}
+	if got := s.find("backup"); got != backup || got.Spec != "@hourly" || got.state.lastSuccess.IsZero() {
+		t.Errorf("update did not retain state of rescheduled job")
+	}
+	if backup.id == backupID || s.cron.Entry(backupID).Valid() {
+		t.Errorf("update did not replace cron entry of rescheduled job")
+	}
+	if s.find("forget") != nil || s.cron.Entry(forgetID).Valid() {
+		t.Errorf("update did not remove job 'forget'")
+	}
+	if s.find("check") == nil {
+		t.Errorf("update did not add job 'check'")
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	now := time.Date(2022, 1, 31, 22, 30, 0, 0, time.UTC)
+	last := time.Date(2022, 1, 31, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tag     string
+		catchup CatchupPolicy
+		want    int
+	}{
+		{"backup", CatchupPolicy(CatchupNone), 0},
+		{"backup", CatchupPolicy(CatchupOnce), 1},
+		{"backup", CatchupPolicy(CatchupAll), 3},
+		{"check", CatchupPolicy(CatchupAll), 0}, // no previous run
+	}
+	for _, tt := range tests {
+		store := NewHistoryStore(t.TempDir())
+		record := NewHistoryRecord("backup", Result(Done), last, last.Add(time.Minute), nil, nil)
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Cannot append history: %s", err.Error())
+		}
+
+		var job Job
+		job.Tag = tt.tag
+		job.Spec = "@hourly"
+		job.Catchup = tt.catchup
+		job.RunE = func(ctx context.Context) error { return nil }
+		s := newScheduler([]Job{job}, CronOptions{History: store})
+		s.catchUp(now)
+
+		if got := len(s.jobChan); got != tt.want {
+			t.Errorf("catchUp released incorrect number of '%s' runs with policy '%s', got: %d, want: %d.",
+				tt.tag, tt.catchup, got, tt.want)
+		}
+	}
+}
+
+func TestCatchUpReload(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	now := time.Now()
+	store := NewHistoryStore(t.TempDir())
+	if err := store.Append(NewHistoryRecord("backup", Result(Done), now.Add(-48*time.Hour), now, nil, nil)); err != nil {
+		t.Fatalf("Cannot append history: %s", err.Error())
+	}
+
+	var backup, check Job
+	backup.Tag, backup.Spec, backup.Catchup = "backup", "@hourly", CatchupPolicy(CatchupOnce)
+	backup.RunE = func(ctx context.Context) error { return nil }
+	check.Tag, check.Spec, check.Catchup = "check", "@hourly", CatchupPolicy(CatchupNone)
+	check.RunE = func(ctx context.Context) error { return nil }
+	jobs := []Job{backup, check}
+	s := newScheduler(jobs, CronOptions{History: store})
+
+	// a reload during catch-up updates the jobs concurrently, run with -race to detect unsynchronized access
+	reloaded := []Job{backup, check}
+	reloaded[0].Spec, reloaded[1].Spec = "@daily", "@daily"
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				s.update(reloaded)
+				s.update(jobs)
+			}
+		}
+	}()
+	s.catchUp(now)
+	close(stop)
+	<-done
+
+	if got := len(s.jobChan); got != 1 {
+		t.Errorf("catchUp released incorrect number of runs, got: %d, want: 1.", got)
+	}
+}
+
+func TestParseCatchupPolicy(t *testing.T) {
+	for _, policy := range []string{"none", "once", "all"} {
+		p, err := ParseCatchupPolicy(policy)
+		if err != nil || p.String() != policy {
+			t.Errorf("ParseCatchupPolicy returned incorrect result for '%s', got: %s, %v.", policy, p, err)
+		}
+	}
+	if _, err := ParseCatchupPolicy("always"); err == nil {
+		t.Errorf("ParseCatchupPolicy did not return an error for unknown policy")
+	}
+}
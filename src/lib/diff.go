@@ -0,0 +1,185 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// DiffSummary defines the differences between two snapshots, as reported by 'restic diff --json'. FilesModified
+// counts the files of which the content changed. SizeDelta defines the net change in size in bytes, which is negative
+// if more data was removed than added.
+type DiffSummary struct {
+	Source        string `json:"source" yaml:"source"`
+	Target        string `json:"target" yaml:"target"`
+	FilesAdded    int    `json:"files_added" yaml:"files_added"`
+	FilesRemoved  int    `json:"files_removed" yaml:"files_removed"`
+	FilesModified int    `json:"files_modified" yaml:"files_modified"`
+	BytesAdded    uint64 `json:"bytes_added" yaml:"bytes_added"`
+	BytesRemoved  uint64 `json:"bytes_removed" yaml:"bytes_removed"`
+	SizeDelta     int64  `json:"size_delta" yaml:"size_delta"`
+}
+
+// diffMessage defines the structure of a JSON-formatted message produced by 'restic diff --json'. The message type
+// is either 'change' or 'statistics'. Only the fields of interest are captured.
+type diffMessage struct {
+	MessageType    string `json:"message_type"`
+	Path           string `json:"path"`
+	Modifier       string `json:"modifier"`
+	SourceSnapshot string `json:"source_snapshot"`
+	TargetSnapshot string `json:"target_snapshot"`
+	Added          struct {
+		Bytes uint64 `json:"bytes"`
+	} `json:"added"`
+	Removed struct {
+		Bytes uint64 `json:"bytes"`
+	} `json:"removed"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// row converts the diff summary to a table row with the columns "Source", "Target", "Added", "Removed", "Modified",
+// and "Size Delta".
+func (d *DiffSummary) row() []string {
+	return []string{d.Source, d.Target, strconv.Itoa(d.FilesAdded), strconv.Itoa(d.FilesRemoved),
+		strconv.Itoa(d.FilesModified), fmt.Sprintf("%+d", d.SizeDelta)}
+}
+
+// diffBackup compares the snapshot created by a backup with the snapshot before it, both matching the filter. The
+// differences are logged and attached to the backup summary, which makes them available to notifications. A failed
+// comparison is logged as warning only, as the backup itself succeeded.
+func (r *ResticManager) diffBackup(summary *BackupSummary, filter SnapshotFilter) {
+	if summary == nil {
+		return
+	}
+	diff, err := r.Diff("", "", filter)
+	if err != nil {
+		Logger.Warn().Err(err).Msg("Could not compare snapshot with previous snapshot")
+		return
+	}
+	Logger.Info().EmbedObject(diff).Msg("Snapshot diff")
+	summary.Diff = diff
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// MarshalZerologObject implements the zerolog.LogObjectMarshaler interface, which allows a diff summary to be
+// embedded as structured fields in a log message.
+func (d *DiffSummary) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("source", d.Source).
+		Str("target", d.Target).
+		Int("files_added", d.FilesAdded).
+		Int("files_removed", d.FilesRemoved).
+		Int("files_modified", d.FilesModified).
+		Int64("size_delta", d.SizeDelta)
+}
+
+// ParseDiffOutput scans the output of 'restic diff --json' line by line and returns the diff summary. Changed
+// directories (with a trailing '/') are not counted as files. Lines that cannot be parsed are written to the debug
+// logger and are skipped. ParseDiffOutput returns an error if the output does not contain any statistics.
+func ParseDiffOutput(output []byte) (*DiffSummary, error) {
+	summary := &DiffSummary{}
+	found := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg diffMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				Logger.Debug().Msgf("Skipping diff output: %s", line)
+			}
+			continue
+		}
+
+		switch msg.MessageType {
+		case "change":
+			if strings.HasSuffix(msg.Path, "/") {
+				continue
+			}
+			switch {
+			case msg.Modifier == "+":
+				summary.FilesAdded++
+			case msg.Modifier == "-":
+				summary.FilesRemoved++
+			case strings.Contains(msg.Modifier, "M"):
+				summary.FilesModified++
+			}
+		case "statistics":
+			found = true
+			summary.Source, summary.Target = msg.SourceSnapshot, msg.TargetSnapshot
+			summary.BytesAdded, summary.BytesRemoved = msg.Added.Bytes, msg.Removed.Bytes
+			summary.SizeDelta = int64(msg.Added.Bytes) - int64(msg.Removed.Bytes)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("Diff output does not contain statistics")
+	}
+	return summary, nil
+}
+
+// Diff compares the snapshot identified by source with the snapshot identified by target and returns a summary of
+// the differences. If source and target are empty, the latest snapshot matching the filter is compared with the
+// snapshot before it.
+func (r *ResticManager) Diff(source string, target string, filter SnapshotFilter) (*DiffSummary, error) {
+	if source == "" && target == "" {
+		snapshots, err := r.ListSnapshots(filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) < 2 {
+			return nil, &ResticError{Err: "Requires at least two snapshots to compare", Fatal: false}
+		}
+		sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+		source, target = snapshots[len(snapshots)-2].ID, snapshots[len(snapshots)-1].ID
+	}
+
+	output, err := r.Output("diff", source, target, "--json")
+	if err != nil {
+		return nil, &ResticError{Err: "Could not compare snapshots", Fatal: false, Cause: err}
+	}
+	return ParseDiffOutput(output)
+}
+
+// ShowDiff displays the differences between two snapshots, see Diff. The summary is rendered as a table, as JSON, or
+// as YAML.
+func (r *ResticManager) ShowDiff(source string, target string, filter SnapshotFilter, format OutputFormat) error {
+	// log progress at debug level only, to keep JSON and YAML output parsable
+	Logger.Debug().Msg("Comparing snapshots")
+
+	summary, err := r.Diff(source, target, filter)
+	if err != nil {
+		return err
+	}
+
+	header := []string{"Source", "Target", "Added", "Removed", "Modified", "Size Delta"}
+	output, err := Render(format, header, [][]string{summary.row()}, summary)
+	if err != nil {
+		return &ResticError{Err: "Could not render diff", Fatal: true}
+	}
+	LogLines(output)
+
+	Logger.Debug().Msg("Finished comparing snapshots")
+	return nil
+}
@@ -0,0 +1,39 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"testing"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestParseDiffOutput(t *testing.T) {
+	output := `{"message_type":"change","path":"/data/docs/","modifier":"M"}
+{"message_type":"change","path":"/data/docs/new.txt","modifier":"+"}
+{"message_type":"change","path":"/data/docs/old.txt","modifier":"-"}
+{"message_type":"change","path":"/data/docs/gone.txt","modifier":"-"}
+{"message_type":"change","path":"/data/docs/report.pdf","modifier":"M"}
+{"message_type":"change","path":"/data/docs/mode.sh","modifier":"U"}
+unexpected output
+{"message_type":"statistics","source_snapshot":"5845b002","target_snapshot":"2ab627a6","changed_files":5,` +
+		`"added":{"files":1,"bytes":1024},"removed":{"files":2,"bytes":4096}}
+`
+	want := DiffSummary{Source: "5845b002", Target: "2ab627a6", FilesAdded: 1, FilesRemoved: 2, FilesModified: 1,
+		BytesAdded: 1024, BytesRemoved: 4096, SizeDelta: -3072}
+
+	got, err := ParseDiffOutput([]byte(output))
+	if err != nil {
+		t.Fatalf("ParseDiffOutput returned an error: %s.", err.Error())
+	}
+	if *got != want {
+		t.Errorf("ParseDiffOutput was incorrect, got: %+v, want: %+v.", *got, want)
+	}
+
+	if _, err := ParseDiffOutput([]byte("no statistics")); err == nil {
+		t.Errorf("ParseDiffOutput did not return an error for output without statistics.")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// DefaultDockerSocket defines the default path of the Unix socket of the Docker Engine API.
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// Supported actions to quiesce containers during a backup.
+const (
+	DockerStop  = "stop"
+	DockerPause = "pause"
+)
+
+// dockerRequestTimeout defines the maximum duration of a single request to the Docker Engine API, excluding the
+// grace period of stopping a container.
+const dockerRequestTimeout = 30 * time.Second
+
+// DockerOptions defines the containers to stop or pause during a backup, identified by a label (e.g.
+// 'restic-unattended.stop=true' or 'restic-unattended.stop'). Action is either 'stop' (default) or 'pause'. Timeout
+// defines the grace period of a container to stop before it is killed, it defaults to the Docker default. Socket
+// defines the path of the Unix socket of the Docker Engine API, it defaults to /var/run/docker.sock.
+type DockerOptions struct {
+	Socket  string        `mapstructure:"socket"`
+	Label   string        `mapstructure:"label"`
+	Action  string        `mapstructure:"action"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// DockerClient invokes the Docker Engine API over a Unix socket.
+type DockerClient struct {
+	client *http.Client
+}
+
+// dockerContainer defines the fields of a container as returned by the Docker Engine API.
+type dockerContainer struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// name returns a human-readable name of the container.
+func (c dockerContainer) name() string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// do sends a request to the Docker Engine API and decodes the JSON response into v, if provided. It returns an error
+// if the API responds with an unexpected status code. The status 304 (not modified) is accepted, as it indicates a
+// container is already in the requested state. The request is aborted when ctx is done.
+func (d *DockerClient) do(ctx context.Context, method string, path string, query url.Values, v interface{}) error {
+	u := url.URL{Scheme: "http", Host: "docker", Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &msg) != nil || msg.Message == "" {
+			msg.Message = http.StatusText(resp.StatusCode)
+		}
+		return fmt.Errorf("Docker API returned status %d: %s", resp.StatusCode, msg.Message)
+	}
+
+	if v != nil {
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+	return nil
+}
+
+// containers returns the running containers with the provided label.
+func (d *DockerClient) containers(ctx context.Context, label string) ([]dockerContainer, error) {
+	filters, err := json.Marshal(map[string][]string{"label": {label}})
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []dockerContainer
+	query := url.Values{"filters": {string(filters)}}
+	if err := d.do(ctx, http.MethodGet, "/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// quiesce stops or pauses a container.
+func (d *DockerClient) quiesce(ctx context.Context, id string, action string, timeout time.Duration) error {
+	if action == DockerPause {
+		return d.do(ctx, http.MethodPost, "/containers/"+id+"/pause", nil, nil)
+	}
+	query := url.Values{}
+	if timeout > 0 {
+		query.Set("t", fmt.Sprintf("%d", int(timeout.Seconds())))
+	}
+	return d.do(ctx, http.MethodPost, "/containers/"+id+"/stop", query, nil)
+}
+
+// resume starts or unpauses a container, reverting quiesce.
+func (d *DockerClient) resume(ctx context.Context, id string, action string) error {
+	if action == DockerPause {
+		return d.do(ctx, http.MethodPost, "/containers/"+id+"/unpause", nil, nil)
+	}
+	return d.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil)
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// NewDockerClient creates a new client for the Docker Engine API listening on the provided Unix socket. The request
+// timeout is extended with the provided grace period, as stopping a container blocks until it has stopped.
+func NewDockerClient(socket string, grace time.Duration) *DockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &DockerClient{client: &http.Client{Transport: transport, Timeout: dockerRequestTimeout + grace}}
+}
+
+// Validate returns an error if the Docker options have no label or an unsupported action.
+func (o *DockerOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Label == "" {
+		return errors.New("No Docker label provided")
+	}
+	switch o.Action {
+	case "", DockerStop, DockerPause:
+	default:
+		return fmt.Errorf("Unsupported Docker action '%s'", o.Action)
+	}
+	return nil
+}
+
+// Wrap stops or pauses all running containers with the configured label, invokes backup, and restarts the containers
+// afterwards. The containers are restarted even if the backup fails. The backup is skipped if a container cannot be
+// stopped, in which case the containers that were stopped already are restarted, as is the failing container since
+// it may have stopped regardless. Requests to list and stop the containers are aborted when ctx is done, the
+// containers are restarted regardless. Wrap is a no-op for nil options.
+func (o *DockerOptions) Wrap(ctx context.Context, backup func() (*BackupSummary, error)) (*BackupSummary, error) {
+	if o == nil {
+		return backup()
+	}
+
+	socket := o.Socket
+	if socket == "" {
+		socket = DefaultDockerSocket
+	}
+	action := o.Action
+	if action == "" {
+		action = DockerStop
+	}
+	d := NewDockerClient(socket, o.Timeout)
+
+	containers, err := d.containers(ctx, o.Label)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list containers with label '%s': %s", o.Label, err.Error())
+	}
+
+	// restart all quiesced containers in reverse order when done, regardless of the outcome of the backup; use a
+	// separate context, as ctx may be done already
+	var quiesced []dockerContainer
+	defer func() {
+		restartCtx, cancel := context.WithTimeout(context.Background(), dockerRequestTimeout)
+		defer cancel()
+		for i := len(quiesced) - 1; i >= 0; i-- {
+			c := quiesced[i]
+			Logger.Info().Msgf("Restarting container '%s'", c.name())
+			if err := d.resume(restartCtx, c.ID, action); err != nil {
+				Logger.Error().Err(err).Msgf("Could not restart container '%s'", c.name())
+			}
+		}
+	}()
+
+	// track each container before requesting it to stop, as it may stop even if the request fails or times out
+	for _, c := range containers {
+		Logger.Info().Msgf("Stopping container '%s' (%s)", c.name(), action)
+		quiesced = append(quiesced, c)
+		if err := d.quiesce(ctx, c.ID, action, o.Timeout); err != nil {
+			return nil, fmt.Errorf("Could not %s container '%s': %s", action, c.name(), err.Error())
+		}
+	}
+
+	return backup()
+}
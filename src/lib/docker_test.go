@@ -0,0 +1,160 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// fakeDocker simulates the Docker Engine API on a Unix socket. It records all requests and the containers that are
+// stopped or paused. Requests of which the path matches fail return an error, after changing the container state.
+type fakeDocker struct {
+	mu       sync.Mutex
+	requests []string
+	stopped  map[string]bool
+	fail     string
+}
+
+// serve starts the fake Docker Engine API and returns the path of its socket.
+func (f *fakeDocker) serve(t *testing.T) string {
+	socket := path.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Cannot listen on socket: %s", err.Error())
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+		if parts := strings.Split(r.URL.Path, "/"); len(parts) == 4 && parts[1] == "containers" {
+			if f.stopped == nil {
+				f.stopped = map[string]bool{}
+			}
+			switch parts[3] {
+			case "stop", "pause":
+				f.stopped[parts[2]] = true
+			case "start", "unpause":
+				delete(f.stopped, parts[2])
+			}
+		}
+		f.mu.Unlock()
+
+		switch {
+		case f.fail != "" && strings.HasPrefix(r.URL.Path, f.fail):
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"simulated failure"}`))
+		case r.URL.Path == "/containers/json":
+			w.Write([]byte(`[{"Id":"aaa","Names":["/db"]},{"Id":"bbb","Names":["/app"]}]`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestDockerOptionsValidate(t *testing.T) {
+	tables := []struct {
+		opts    *DockerOptions
+		isValid bool
+	}{
+		{nil, true},
+		{&DockerOptions{Label: "restic-unattended.stop=true"}, true},
+		{&DockerOptions{Label: "restic-unattended.stop", Action: DockerPause}, true},
+		{&DockerOptions{}, false},
+		{&DockerOptions{Label: "restic-unattended.stop", Action: "kill"}, false},
+	}
+
+	for i, table := range tables {
+		err := table.opts.Validate()
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("Validate %d was incorrect, got: %t, want: %t.", i+1, isValid, table.isValid)
+		}
+	}
+}
+
+func TestDockerWrap(t *testing.T) {
+	filter := "/containers/json?filters=%7B%22label%22%3A%5B%22backup%22%5D%7D"
+	tables := []struct {
+		action   string
+		fail     string
+		backup   error
+		called   bool
+		requests []string
+	}{
+		{DockerStop, "", nil, true, []string{"GET " + filter, "POST /containers/aaa/stop?t=5",
+			"POST /containers/bbb/stop?t=5", "POST /containers/bbb/start", "POST /containers/aaa/start"}},
+		{DockerStop, "", errors.New("backup failed"), true, []string{"GET " + filter, "POST /containers/aaa/stop?t=5",
+			"POST /containers/bbb/stop?t=5", "POST /containers/bbb/start", "POST /containers/aaa/start"}},
+		{DockerPause, "", nil, true, []string{"GET " + filter, "POST /containers/aaa/pause",
+			"POST /containers/bbb/pause", "POST /containers/bbb/unpause", "POST /containers/aaa/unpause"}},
+		{DockerStop, "/containers/bbb/stop", nil, false, []string{"GET " + filter, "POST /containers/aaa/stop?t=5",
+			"POST /containers/bbb/stop?t=5", "POST /containers/bbb/start", "POST /containers/aaa/start"}},
+		{DockerStop, "/containers/json", nil, false, []string{"GET " + filter}},
+	}
+
+	for i, table := range tables {
+		f := &fakeDocker{fail: table.fail}
+		opts := &DockerOptions{Socket: f.serve(t), Label: "backup", Action: table.action, Timeout: 5 * time.Second}
+
+		called := false
+		_, err := opts.Wrap(context.Background(), func() (*BackupSummary, error) {
+			called = true
+			return nil, table.backup
+		})
+		if called != table.called {
+			t.Errorf("Wrap %d invoked backup incorrectly, got: %t, want: %t.", i+1, called, table.called)
+		}
+		if wantErr := table.backup != nil || table.fail != ""; (err != nil) != wantErr {
+			t.Errorf("Wrap %d returned incorrect error, got: %v, want error: %t.", i+1, err, wantErr)
+		}
+		if !reflect.DeepEqual(f.requests, table.requests) {
+			t.Errorf("Wrap %d sent incorrect requests, got: %v, want: %v.", i+1, f.requests, table.requests)
+		}
+		if len(f.stopped) > 0 {
+			t.Errorf("Wrap %d did not restart all containers, got stopped: %v.", i+1, f.stopped)
+		}
+	}
+}
+
+func TestDockerWrapCancel(t *testing.T) {
+	f := &fakeDocker{}
+	opts := &DockerOptions{Socket: f.serve(t), Label: "backup", Action: DockerPause}
+
+	// containers are restarted even if the context of the job is done during the backup
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts.Wrap(ctx, func() (*BackupSummary, error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	want := []string{"POST /containers/bbb/unpause", "POST /containers/aaa/unpause"}
+	if len(f.requests) != 5 || !reflect.DeepEqual(f.requests[3:], want) {
+		t.Errorf("Wrap did not restart containers after cancel, got: %v, want suffix: %v.", f.requests, want)
+	}
+
+	// no containers are stopped if the context is done already
+	f.requests = nil
+	if _, err := opts.Wrap(ctx, func() (*BackupSummary, error) { return nil, nil }); err == nil || len(f.requests) > 0 {
+		t.Errorf("Wrap did not abort for a done context, got error: %v, requests: %v.", err, f.requests)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+//======================================================================================================================
+// Constants
+//======================================================================================================================
+
+// DefaultStatusFile defines the default path of the status file written by the scheduler.
+const DefaultStatusFile = "/tmp/restic-unattended.status"
+
+// setJobTypes defines the types of scheduled jobs that support backup sets. The tag of a job consists of its type
+// (e.g. 'backup' or 'restore-test'), followed by '-<set>' for the jobs of a backup set and by '@<profile>' for the jobs
+// targeting a repository profile.
+var setJobTypes = []string{"backup", "forget"}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// writeStatus writes the current scheduler status as JSON to the status file, unless no status file is defined. The
+// file is replaced atomically to prevent partial reads. Errors are written to the logger.
+func (s *scheduler) writeStatus() {
+	if s.statusFile == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(s.schedulerStatus(), "", "  ")
+	if err == nil {
+		err = WriteFileAtomic(s.statusFile, data)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msgf("Could not write status file '%s'", s.statusFile)
+	}
+}
+
+// tagScopes returns the tags that identify a job, ordered from most to least specific: the job tag itself, the tag of
+// its backup set without repository profile, and the tag of its job type. For example, the job 'backup-db@b2' is
+// identified by 'backup-db@b2', 'backup-db', and 'backup'. Only the suffixes of the job tag are stripped, a job type is
+// never matched by another job type sharing its prefix (e.g. 'restore' does not identify 'restore-test').
+func tagScopes(tag string) []string {
+	scopes := []string{tag}
+	base := tag
+	if i := strings.LastIndex(tag, "@"); i >= 0 {
+		base = tag[:i]
+		scopes = append(scopes, base)
+	}
+	for _, t := range setJobTypes {
+		if strings.HasPrefix(base, t+"-") {
+			return append(scopes, t)
+		}
+	}
+	return scopes
+}
+
+// matchesTag returns true if one of the tags identifies the job tag, see tagScopes.
+func matchesTag(tags []string, tag string) bool {
+	_, ok := bestTag(tags, tag)
+	return ok
+}
+
+// bestTag returns the tag of tags that identifies the job tag most specifically, see tagScopes. It returns false if
+// none of the tags matches.
+func bestTag(tags []string, tag string) (string, bool) {
+	for _, scope := range tagScopes(tag) {
+		if Contains(tags, scope) {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// CheckHealth validates the scheduler status for the jobs identified by tags. A job is considered unhealthy if its
+// most recent run failed, or if its last successful run is older than maxAge. Jobs that have not run yet are measured
+// from the moment the scheduler started. A maxAge of zero disables the age check. A tag also matches the jobs of a
+// backup set or repository profile, e.g. 'backup' matches 'backup-<name>' and 'backup@<profile>'. CheckHealth
+// returns an error if at least one job is unhealthy, or if none of the tags can be found in the status.
+func CheckHealth(status *SchedulerStatus, tags []string, maxAge time.Duration, now time.Time) error {
+	found := false
+	for _, job := range status.Jobs {
+		if !matchesTag(tags, job.Tag) {
+			continue
+		}
+		found = true
+
+		if job.LastResult != "" && job.LastResult != Result(Done).String() {
+			return fmt.Errorf("Last '%s' job failed: %s", job.Tag, job.LastError)
+		}
+
+		last := status.Started
+		if job.LastSuccess != nil {
+			last = *job.LastSuccess
+		}
+		if maxAge > 0 && now.Sub(last) > maxAge {
+			return fmt.Errorf("Last successful '%s' job is older than %s", job.Tag, maxAge)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("No job found with tag(s) %v", tags)
+	}
+	return nil
+}
+
+// ReadStatusFile reads the scheduler status from a status file written by the schedule command.
+func ReadStatusFile(path string) (*SchedulerStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var status SchedulerStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("Cannot parse status file '%s'", path)
+	}
+	return &status, nil
+}
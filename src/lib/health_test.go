@@ -0,0 +1,101 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"path"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Hour)
+	old := now.Add(-48 * time.Hour)
+
+	tables := []struct {
+		name    string
+		job     JobStatus
+		started time.Time
+		maxAge  time.Duration
+		healthy bool
+	}{
+		{"no runs yet", JobStatus{Tag: "backup"}, recent, 25 * time.Hour, true},
+		{"no runs since long", JobStatus{Tag: "backup"}, old, 25 * time.Hour, false},
+		{"recent success", JobStatus{Tag: "backup", LastResult: "done", LastSuccess: &recent}, old, 25 * time.Hour,
+			true},
+		{"old success", JobStatus{Tag: "backup", LastResult: "done", LastSuccess: &old}, old, 25 * time.Hour, false},
+		{"old success without max age", JobStatus{Tag: "backup", LastResult: "done", LastSuccess: &old}, old, 0,
+			true},
+		{"failed", JobStatus{Tag: "backup", LastResult: "error", LastSuccess: &recent}, old, 25 * time.Hour, false},
+		{"other tag", JobStatus{Tag: "forget", LastResult: "error"}, recent, 0, false},
+		{"backup set failed", JobStatus{Tag: "backup-docs", LastResult: "error"}, recent, 0, false},
+		{"backup set success", JobStatus{Tag: "backup-docs", LastResult: "done", LastSuccess: &recent}, old,
+			25 * time.Hour, true},
+		{"profile failed", JobStatus{Tag: "backup@nas", LastResult: "error"}, recent, 0, false},
+		{"similar tag", JobStatus{Tag: "backups", LastResult: "done"}, recent, 0, false},
+	}
+
+	for _, table := range tables {
+		status := &SchedulerStatus{Started: table.started, Jobs: []JobStatus{table.job}}
+		err := CheckHealth(status, []string{"backup"}, table.maxAge, now)
+		if healthy := err == nil; healthy != table.healthy {
+			t.Errorf("CheckHealth '%s' was incorrect, got: %t, want: %t.", table.name, healthy, table.healthy)
+		}
+	}
+}
+
+func TestReadStatusFile(t *testing.T) {
+	s := prepareScheduler()
+	s.statusFile = path.Join(t.TempDir(), "status")
+	s.process(*s.jobs[0])
+
+	status, err := ReadStatusFile(s.statusFile)
+	if err != nil {
+		t.Errorf("ReadStatusFile returned an error: %s.", err.Error())
+		return
+	}
+	if len(status.Jobs) != 1 || status.Jobs[0].LastResult != "error" {
+		t.Errorf("ReadStatusFile returned incorrect status, got: %+v.", status)
+	}
+	if err := CheckHealth(status, []string{"backup"}, 0, time.Now()); err == nil {
+		t.Errorf("CheckHealth returned unexpected result, got: nil, want: error")
+	}
+
+	if _, err := ReadStatusFile(path.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("ReadStatusFile returned unexpected result, got: nil, want: error")
+	}
+}
+
+func TestBestTag(t *testing.T) {
+	tables := []struct {
+		tags     []string
+		tag      string
+		expected string
+		ok       bool
+	}{
+		{[]string{"backup"}, "backup", "backup", true},
+		{[]string{"backup"}, "backup-db@b2", "backup", true},
+		{[]string{"backup", "backup-db"}, "backup-db@b2", "backup-db", true},
+		{[]string{"backup", "backup-db", "backup-db@b2"}, "backup-db@b2", "backup-db@b2", true},
+		{[]string{"check"}, "check@b2", "check", true},
+		{[]string{"backup-db"}, "backup-db-archive", "", false},
+		{[]string{"backup-db"}, "backup-db-archive@b2", "", false},
+		{[]string{"restore"}, "restore-test", "", false},
+		{[]string{"restore"}, "restore-test@b2", "", false},
+		{[]string{"backup@b2"}, "backup-db@b2", "", false},
+		{[]string{"forget"}, "backup-db", "", false},
+	}
+
+	for _, table := range tables {
+		tag, ok := bestTag(table.tags, table.tag)
+		if tag != table.expected || ok != table.ok {
+			t.Errorf("bestTag(%v, '%s') = ('%s', %v), want ('%s', %v)", table.tags, table.tag, tag, ok,
+				table.expected, table.ok)
+		}
+		if matchesTag(table.tags, table.tag) != table.ok {
+			t.Errorf("matchesTag(%v, '%s') = %v, want %v", table.tags, table.tag, !table.ok, table.ok)
+		}
+	}
+}
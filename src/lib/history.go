@@ -0,0 +1,214 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// historyFile defines the file name of the job history in the state directory.
+const historyFile = "history.jsonl"
+
+// HistoryRecord defines a single run of a scheduled job. Result is either 'done', 'error', or 'fatal'. Summary holds
+// the parsed restic summary of backup jobs.
+type HistoryRecord struct {
+	Tag     string         `json:"tag" yaml:"tag"`
+	Start   time.Time      `json:"start" yaml:"start"`
+	End     time.Time      `json:"end" yaml:"end"`
+	Result  string         `json:"result" yaml:"result"`
+	Error   string         `json:"error,omitempty" yaml:"error,omitempty"`
+	Summary *BackupSummary `json:"summary,omitempty" yaml:"summary,omitempty"`
+}
+
+// HistoryFilter selects records of the job history. Tags select the jobs by tag, a tag also matches the jobs of a
+// backup set or repository profile derived from it (see CheckHealth). Since and Until restrict the start time of the
+// runs, inclusive. Empty or zero fields match all records.
+type HistoryFilter struct {
+	Tags  []string
+	Since time.Time
+	Until time.Time
+}
+
+// HistoryStore persists the runs of scheduled jobs as an append-only file in the JSON Lines format, with one record
+// per line. Records are never modified or removed, which provides an audit trail of the jobs across restarts.
+type HistoryStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// matches returns true if the record is selected by the filter.
+func (f HistoryFilter) matches(record HistoryRecord) bool {
+	if len(f.Tags) > 0 && !matchesTag(f.Tags, record.Tag) {
+		return false
+	}
+	if !f.Since.IsZero() && record.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Start.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// row converts the record to a table row with the columns "Tag", "Start", "Duration", "Result", and "Error".
+func (r HistoryRecord) row() []string {
+	duration := r.End.Sub(r.Start).Round(time.Second)
+	return []string{r.Tag, r.Start.Local().Format("2006-01-02 15:04:05"), duration.String(), r.Result, r.Error}
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// NewHistoryStore creates a new history store persisting the job history in dir. The directory is created when the
+// first record is appended.
+func NewHistoryStore(dir string) *HistoryStore {
+	return &HistoryStore{path: filepath.Join(dir, historyFile)}
+}
+
+// NewHistoryRecord creates a history record for a job run.
+func NewHistoryRecord(tag string, result Result, start time.Time, end time.Time, err error,
+	summary *BackupSummary) HistoryRecord {
+
+	record := HistoryRecord{Tag: tag, Start: start, End: end, Result: result.String(), Summary: summary}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// ParseHistoryTime converts value to a point in time. The value is either a timestamp in RFC 3339 format (e.g.
+// '2022-01-31T22:00:00Z'), a date (e.g. '2022-01-31', in local time), or a duration relative to now (e.g. '24h').
+func ParseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid time '%s', expected RFC 3339 timestamp, date, or duration", value)
+}
+
+// Append adds a record to the end of the history. The record is synced to disk before Append returns.
+func (s *HistoryStore) Append(record HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query returns the records matching the filter in the order they were appended. Lines that cannot be parsed, such as
+// a line truncated by a crash, are written to the debug logger and are skipped. The history is empty if the file
+// does not exist yet.
+func (s *HistoryStore) Query(filter HistoryFilter) ([]HistoryRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []HistoryRecord{}
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				Logger.Debug().Msgf("Skipping history record: %s", line)
+			}
+			continue
+		}
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Last returns the most recent record of each job, identified by tag.
+func (s *HistoryStore) Last() (map[string]HistoryRecord, error) {
+	records, err := s.Query(HistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]HistoryRecord{}
+	for _, r := range records {
+		if prev, ok := last[r.Tag]; !ok || !r.Start.Before(prev.Start) {
+			last[r.Tag] = r
+		}
+	}
+	return last, nil
+}
+
+// ShowHistory displays the records of the history in dir matching the filter. The records are rendered as a table,
+// as JSON, or as YAML.
+func ShowHistory(dir string, filter HistoryFilter, format OutputFormat) error {
+	// log progress at debug level only, to keep JSON and YAML output parsable
+	Logger.Debug().Msg("Reading job history")
+
+	records, err := NewHistoryStore(dir).Query(filter)
+	if err != nil {
+		return &ResticError{Err: fmt.Sprintf("Could not read job history: %s", err.Error()), Fatal: true}
+	}
+
+	header := []string{"Tag", "Start", "Duration", "Result", "Error"}
+	rows := [][]string{}
+	for _, r := range records {
+		rows = append(rows, r.row())
+	}
+	output, err := Render(format, header, rows, records)
+	if err != nil {
+		return &ResticError{Err: "Could not render job history", Fatal: true}
+	}
+	LogLines(output)
+
+	Logger.Debug().Msg("Finished reading job history")
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestHistoryStore(t *testing.T) {
+	dir := path.Join(t.TempDir(), "state")
+	store := NewHistoryStore(dir)
+
+	// querying a missing history returns no records
+	records, err := store.Query(HistoryFilter{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("Query returned unexpected result for missing history, got: %v, %v.", records, err)
+	}
+
+	start := time.Date(2022, 1, 31, 22, 0, 0, 0, time.UTC)
+	runs := []HistoryRecord{
+		NewHistoryRecord("backup", Result(Done), start, start.Add(time.Minute), nil, &BackupSummary{FilesNew: 3}),
+		NewHistoryRecord("forget@b2", Result(Error), start.Add(time.Hour), start.Add(2*time.Hour),
+			errors.New("forget failed"), nil),
+		NewHistoryRecord("backup-db", Result(Fatal), start.Add(24*time.Hour), start.Add(25*time.Hour), nil, nil),
+	}
+	for _, r := range runs {
+		if err := store.Append(r); err != nil {
+			t.Fatalf("Append returned an error: %s.", err.Error())
+		}
+	}
+
+	// a truncated line is skipped
+	f, err := os.OpenFile(path.Join(dir, historyFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Cannot open history: %s", err.Error())
+	}
+	f.WriteString(`{"tag":"back`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   []string
+	}{
+		{"all", HistoryFilter{}, []string{"backup", "forget@b2", "backup-db"}},
+		{"tag", HistoryFilter{Tags: []string{"backup"}}, []string{"backup", "backup-db"}},
+		{"since", HistoryFilter{Since: start.Add(time.Hour)}, []string{"forget@b2", "backup-db"}},
+		{"until", HistoryFilter{Until: start.Add(time.Hour)}, []string{"backup", "forget@b2"}},
+		{"range", HistoryFilter{Tags: []string{"backup"}, Since: start.Add(time.Minute)}, []string{"backup-db"}},
+	}
+	for _, tt := range tests {
+		records, err := store.Query(tt.filter)
+		if err != nil {
+			t.Errorf("Query returned an error for filter '%s': %s.", tt.name, err.Error())
+			continue
+		}
+		got := []string{}
+		for _, r := range records {
+			got = append(got, r.Tag)
+		}
+		if !Equal(got, tt.want) {
+			t.Errorf("Query returned incorrect records for filter '%s', got: %v, want: %v.", tt.name, got, tt.want)
+		}
+	}
+
+	records, _ = store.Query(HistoryFilter{Tags: []string{"forget"}})
+	if len(records) != 1 || records[0].Result != "error" || records[0].Error != "forget failed" {
+		t.Errorf("Query returned incorrect record, got: %+v.", records)
+	}
+	records, _ = store.Query(HistoryFilter{Tags: []string{"backup"}})
+	if len(records) == 0 || records[0].Summary == nil || records[0].Summary.FilesNew != 3 ||
+		!records[0].Start.Equal(start) {
+		t.Errorf("Query returned incorrect record, got: %+v.", records)
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2022, 1, 31, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{"2022-01-01T10:00:00Z", time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC), false},
+		{"2022-01-01", time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local), false},
+		{"24h", now.Add(-24 * time.Hour), false},
+		{"-24h", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHistoryTime(tt.value, now)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("ParseHistoryTime returned incorrect result for '%s', got: %s, %v.", tt.value, got, err)
+		}
+	}
+}
+
+func TestJobHistory(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	var job Job
+	job.Tag = "backup"
+	job.Spec = "@every 1h"
+	job.RunE = func(ctx context.Context) error { return errors.New("backup failed") }
+
+	store := NewHistoryStore(t.TempDir())
+	s := newScheduler([]Job{job}, CronOptions{History: store})
+	s.process(*s.jobs[0])
+
+	records, err := store.Query(HistoryFilter{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("process did not append job to history, got: %v, %v.", records, err)
+	}
+	r := records[0]
+	if r.Tag != "backup" || r.Result != "error" || r.Error != "backup failed" || r.End.Before(r.Start) {
+		t.Errorf("process appended incorrect record, got: %+v.", r)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// Supported failure policies of a hook. A failing hook either aborts the backup, logs a warning, or is ignored.
+const (
+	PolicyAbort  = "abort"
+	PolicyWarn   = "warn"
+	PolicyIgnore = "ignore"
+)
+
+// Hook defines an external command that runs before or after a backup, e.g. to create and remove a database dump.
+// The command is terminated if it exceeds its timeout, a timeout of zero disables the limit. OnError defines the
+// failure policy: abort (default), warn, or ignore. An aborting pre-backup hook skips the backup, an aborting
+// post-backup hook marks the backup as failed.
+type Hook struct {
+	Command string        `mapstructure:"command"`
+	Args    []string      `mapstructure:"args"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	OnError string        `mapstructure:"on_error"`
+}
+
+// Hooks defines the commands to run before and after a backup. Post-backup hooks run even if the backup failed.
+type Hooks struct {
+	Pre  []Hook `mapstructure:"pre"`
+	Post []Hook `mapstructure:"post"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// run invokes the hook command with the provided environment variables. Both stdout and stderr of the command are
+// written to the logger in real time. The command is interrupted when ctx is done or when it exceeds its timeout, see
+// wait. It returns an error if the command fails or is interrupted.
+func (h Hook) run(ctx context.Context, env []string) error {
+	hookCtx := ctx
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	Logger.Debug().Msgf("Executing hook: %s %s", h.Command, h.Args)
+	cmd := exec.Command(h.Command, h.Args...)
+	cmd.Env = env
+	cmd.Stdout = NewLogWriter(&Logger, zerolog.InfoLevel)
+	cmd.Stderr = NewLogWriter(&Logger, zerolog.ErrorLevel)
+
+	err := cmd.Start()
+	if err == nil {
+		err = wait(hookCtx, cmd)
+	}
+	if ctx.Err() == nil && hookCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Hook '%s' exceeded timeout of %s", h.Command, h.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Hook '%s' failed: %s", h.Command, err.Error())
+	}
+	return nil
+}
+
+// runHooks invokes each hook in order and applies its failure policy. It returns the error of the first hook that
+// fails with the abort policy, the remaining hooks are skipped in that case.
+func runHooks(ctx context.Context, stage string, hooks []Hook, env []string) error {
+	for _, h := range hooks {
+		err := h.run(ctx, env)
+		if err == nil {
+			continue
+		}
+
+		switch h.OnError {
+		case PolicyWarn:
+			Logger.Warn().Err(err).Msgf("Ignoring failed %s-backup hook", stage)
+		case PolicyIgnore:
+			Logger.Debug().Err(err).Msgf("Ignoring failed %s-backup hook", stage)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// Validate returns an error if a hook has no command or an unsupported failure policy.
+func (h *Hooks) Validate() error {
+	if h == nil {
+		return nil
+	}
+	for _, hook := range append(append([]Hook{}, h.Pre...), h.Post...) {
+		if hook.Command == "" {
+			return errors.New("No command provided for hook")
+		}
+		switch hook.OnError {
+		case "", PolicyAbort, PolicyWarn, PolicyIgnore:
+		default:
+			return fmt.Errorf("Unsupported failure policy '%s' for hook '%s'", hook.OnError, hook.Command)
+		}
+	}
+	return nil
+}
+
+// Wrap runs the pre-backup hooks, invokes backup, and runs the post-backup hooks. The hooks receive the environment
+// variables env, extended with RESTIC_BACKUP_PATH (the backup paths separated by ':'). Post-backup hooks also receive
+// RESTIC_BACKUP_RESULT ('success' or 'failure') and RESTIC_BACKUP_ERROR. The backup is skipped if a pre-backup hook
+// aborts. The hooks are interrupted when ctx is done. Wrap is a no-op for nil hooks.
+func (h *Hooks) Wrap(ctx context.Context, env []string, paths []string,
+	backup func() (*BackupSummary, error)) (*BackupSummary, error) {
+	if h == nil {
+		return backup()
+	}
+
+	env = append(append([]string{}, env...), "RESTIC_BACKUP_PATH="+strings.Join(paths, ":"))
+	if err := runHooks(ctx, "pre", h.Pre, env); err != nil {
+		return nil, err
+	}
+
+	summary, err := backup()
+
+	result, msg := "success", ""
+	if err != nil {
+		result, msg = "failure", err.Error()
+	}
+	env = append(env, "RESTIC_BACKUP_RESULT="+result, "RESTIC_BACKUP_ERROR="+msg)
+	if hookErr := runHooks(ctx, "post", h.Post, env); hookErr != nil {
+		if err == nil {
+			return summary, hookErr
+		}
+		Logger.Error().Err(hookErr).Msg("Post-backup hook failed")
+	}
+	return summary, err
+}
@@ -0,0 +1,118 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestHooksValidate(t *testing.T) {
+	tables := []struct {
+		hooks   *Hooks
+		isValid bool
+	}{
+		{nil, true},
+		{&Hooks{Pre: []Hook{{Command: "/bin/true"}}, Post: []Hook{{Command: "/bin/true", OnError: "warn"}}}, true},
+		{&Hooks{Pre: []Hook{{Command: ""}}}, false},
+		{&Hooks{Post: []Hook{{Command: "/bin/true", OnError: "retry"}}}, false},
+	}
+
+	for i, table := range tables {
+		err := table.hooks.Validate()
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("Validate %d was incorrect, got: %t, want: %t.", i+1, isValid, table.isValid)
+		}
+	}
+}
+
+func TestHooksWrap(t *testing.T) {
+	output := path.Join(t.TempDir(), "output")
+	script := `echo "$0 $RESTIC_BACKUP_PATH $RESTIC_BACKUP_RESULT" >> ` + output
+	hooks := &Hooks{
+		Pre:  []Hook{{Command: "/bin/sh", Args: []string{"-c", script, "pre"}}},
+		Post: []Hook{{Command: "/bin/sh", Args: []string{"-c", script, "post"}}},
+	}
+
+	called := false
+	backup := func() (*BackupSummary, error) {
+		called = true
+		return nil, errors.New("backup failed")
+	}
+	_, err := hooks.Wrap(context.Background(), nil, []string{"/data/a", "/data/b"}, backup)
+	if err == nil || err.Error() != "backup failed" {
+		t.Errorf("Wrap returned incorrect error, got: %v, want: backup failed.", err)
+	}
+	if !called {
+		t.Errorf("Wrap did not invoke the backup")
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Errorf("Hooks did not write output: %s.", err.Error())
+	} else if want := "pre /data/a:/data/b \npost /data/a:/data/b failure\n"; string(got) != want {
+		t.Errorf("Hooks received incorrect environment, got: %q, want: %q.", got, want)
+	}
+}
+
+func TestHooksPolicy(t *testing.T) {
+	tables := []struct {
+		name    string
+		hook    Hook
+		aborted bool
+	}{
+		{"abort", Hook{Command: "/bin/false"}, true},
+		{"warn", Hook{Command: "/bin/false", OnError: PolicyWarn}, false},
+		{"ignore", Hook{Command: "/bin/false", OnError: PolicyIgnore}, false},
+		{"timeout", Hook{Command: "/bin/sleep", Args: []string{"5"}, Timeout: 100 * time.Millisecond}, true},
+		{"success", Hook{Command: "/bin/true"}, false},
+	}
+
+	for _, table := range tables {
+		called := false
+		hooks := &Hooks{Pre: []Hook{table.hook}}
+		_, err := hooks.Wrap(context.Background(), nil, []string{"/data"}, func() (*BackupSummary, error) {
+			called = true
+			return nil, nil
+		})
+		if aborted := err != nil && !called; aborted != table.aborted {
+			t.Errorf("Hook policy '%s' was incorrect, got aborted: %t, want: %t.", table.name, aborted, table.aborted)
+		}
+	}
+
+	// an aborting post-backup hook fails a successful backup
+	hooks := &Hooks{Post: []Hook{{Command: "/bin/false"}}}
+	_, err := hooks.Wrap(context.Background(), nil, []string{"/data"}, func() (*BackupSummary, error) { return nil, nil })
+	if err == nil {
+		t.Errorf("Post-backup hook returned unexpected result, got: nil, want: error")
+	}
+}
+
+func TestHooksCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// a hung hook without timeout is interrupted when the context of the job is done
+	called := false
+	start := time.Now()
+	hooks := &Hooks{Pre: []Hook{{Command: "/bin/sleep", Args: []string{"5"}}}}
+	_, err := hooks.Wrap(ctx, nil, []string{"/data"}, func() (*BackupSummary, error) {
+		called = true
+		return nil, nil
+	})
+	if err == nil || called {
+		t.Errorf("Wrap did not abort the backup, got error: %v, called: %t.", err, called)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Wrap did not interrupt the hook, got duration: %s.", elapsed)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -35,6 +36,20 @@ type LogWriter struct {
 // LogFormat defines the type of logging format to use.
 type LogFormat int
 
+// logTailSize defines the number of recent log messages retained by logTail.
+const logTailSize = 1000
+
+// logTail retains the most recent log messages of the global logger, see TailLogs.
+var logTail = &logRing{lines: make([]string, logTailSize)}
+
+// logRing is a zerolog hook retaining recent log messages in a ring buffer. Each message is identified by a sequence
+// number, which allows callers to retrieve the messages logged since a specific moment.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  uint64
+}
+
 // LogMessage defines the structure of JSON-formatted log messages produced by zerolog.
 type LogMessage struct {
 	Level   zerolog.Level `json:"level"`
@@ -98,7 +113,7 @@ func InitLoggerWithWriter(format LogFormat, w io.Writer, noColor bool) {
 		output = w
 	}
 
-	Logger = zerolog.New(output).With().Timestamp().Logger()
+	Logger = zerolog.New(output).With().Timestamp().Logger().Hook(logTail)
 }
 
 // InitLogger initializes the global logger with the desired format.
@@ -106,6 +121,35 @@ func InitLogger(format LogFormat) {
 	InitLoggerWithWriter(format, os.Stdout, false)
 }
 
+// MarkLogs returns the sequence number of the next log message. Use TailLogs to retrieve the messages logged since.
+func MarkLogs() uint64 {
+	logTail.mu.Lock()
+	defer logTail.mu.Unlock()
+	return logTail.next
+}
+
+// TailLogs returns up to n of the most recent log messages logged since mark, see MarkLogs. Each message is prefixed
+// with its level. Messages that are no longer retained are skipped.
+func TailLogs(mark uint64, n int) []string {
+	logTail.mu.Lock()
+	defer logTail.mu.Unlock()
+
+	size := uint64(len(logTail.lines))
+	start := mark
+	if logTail.next > size && start < logTail.next-size {
+		start = logTail.next - size
+	}
+	if n >= 0 && logTail.next-start > uint64(n) {
+		start = logTail.next - uint64(n)
+	}
+
+	lines := []string{}
+	for i := start; i < logTail.next; i++ {
+		lines = append(lines, logTail.lines[i%size])
+	}
+	return lines
+}
+
 // NewLogWriter returns the global logger with an instruction to write a message at the specified level.
 func NewLogWriter(l *zerolog.Logger, level zerolog.Level) *LogWriter {
 	lw := &LogWriter{}
@@ -126,6 +170,14 @@ func (lw LogWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// Run implements the zerolog.Hook interface for a logRing. It retains the level and message of each log event.
+func (r *logRing) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next%uint64(len(r.lines))] = strings.ToUpper(level.String()) + " " + msg
+	r.next++
+}
+
 // ParseFormat converts a format string into a typed logformat value.
 // returns an error if the input string does not match known values.
 func ParseFormat(formatStr string) (LogFormat, error) {
@@ -0,0 +1,199 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// durationBuckets defines the upper bounds (in seconds) of the job duration histogram.
+var durationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400}
+
+// metricsPrefix defines the common prefix of all exported metrics.
+const metricsPrefix = "restic_unattended_"
+
+// histogram tracks the distribution of observed values across predefined buckets.
+type histogram struct {
+	counts []uint64 // cumulative count for each bucket in durationBuckets
+	count  uint64
+	sum    float64
+}
+
+// runKey identifies a counter of job runs by tag and result.
+type runKey struct {
+	tag    string
+	result Result
+}
+
+// metrics collects statistics of scheduled jobs and exports them in the Prometheus text format. All methods are safe
+// for concurrent use.
+type metrics struct {
+	mu             sync.Mutex
+	tags           []string
+	runs           map[runKey]uint64
+	durations      map[string]*histogram
+	lastSuccess    map[string]time.Time
+	dropped        map[string]uint64
+	dataAdded      map[string]uint64
+	dataAddedTotal map[string]uint64
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// escapeLabel escapes a label value following the Prometheus text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat converts a float to its shortest string representation.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// newMetrics creates an empty metrics collector. Use register to initialize the counters of a job.
+func newMetrics() *metrics {
+	return &metrics{
+		runs:           map[runKey]uint64{},
+		durations:      map[string]*histogram{},
+		lastSuccess:    map[string]time.Time{},
+		dropped:        map[string]uint64{},
+		dataAdded:      map[string]uint64{},
+		dataAddedTotal: map[string]uint64{},
+	}
+}
+
+// drop records a job dropped because the job channel was full.
+func (m *metrics) drop(tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.register(tag)
+	m.dropped[tag]++
+}
+
+// observe records the outcome of a job run. The backup summary is optional.
+func (m *metrics) observe(tag string, result Result, duration time.Duration, summary *BackupSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.register(tag)
+
+	m.runs[runKey{tag, result}]++
+
+	h := m.durations[tag]
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+
+	if result == Result(Done) {
+		m.lastSuccess[tag] = time.Now()
+	}
+	if summary != nil {
+		m.dataAdded[tag] = summary.DataAdded
+		m.dataAddedTotal[tag] += summary.DataAdded
+	}
+}
+
+// register initializes the counters of a job tag to zero, unless the tag is known already. This ensures all series
+// are exported before the first job runs. The caller is expected to hold the lock, or to have exclusive access.
+func (m *metrics) register(tag string) {
+	if _, ok := m.durations[tag]; ok {
+		return
+	}
+	m.tags = append(m.tags, tag)
+	sort.Strings(m.tags)
+	m.durations[tag] = &histogram{counts: make([]uint64, len(durationBuckets))}
+	for _, result := range []Result{Result(Done), Result(Error), Result(Fatal)} {
+		m.runs[runKey{tag, result}] = 0
+	}
+	m.dropped[tag] = 0
+}
+
+// write exports all metrics in the Prometheus text format. The queue depth of the job channel is provided by the
+// caller.
+func (m *metrics) write(w io.Writer, queueDepth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header := func(name string, kind string, help string) {
+		fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+	}
+
+	header("job_runs_total", "counter", "Number of processed job runs by tag and result.")
+	for _, tag := range m.tags {
+		for _, result := range []Result{Result(Done), Result(Error), Result(Fatal)} {
+			fmt.Fprintf(w, "%sjob_runs_total{tag=\"%s\",result=\"%s\"} %d\n", metricsPrefix, escapeLabel(tag),
+				result, m.runs[runKey{tag, result}])
+		}
+	}
+
+	header("job_duration_seconds", "histogram", "Duration of job runs in seconds.")
+	for _, tag := range m.tags {
+		h := m.durations[tag]
+		label := escapeLabel(tag)
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "%sjob_duration_seconds_bucket{tag=\"%s\",le=\"%s\"} %d\n", metricsPrefix, label,
+				formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%sjob_duration_seconds_bucket{tag=\"%s\",le=\"+Inf\"} %d\n", metricsPrefix, label, h.count)
+		fmt.Fprintf(w, "%sjob_duration_seconds_sum{tag=\"%s\"} %s\n", metricsPrefix, label, formatFloat(h.sum))
+		fmt.Fprintf(w, "%sjob_duration_seconds_count{tag=\"%s\"} %d\n", metricsPrefix, label, h.count)
+	}
+
+	header("job_last_success_timestamp_seconds", "gauge", "Unix timestamp of the last successful job run.")
+	for _, tag := range m.tags {
+		if t, ok := m.lastSuccess[tag]; ok {
+			fmt.Fprintf(w, "%sjob_last_success_timestamp_seconds{tag=\"%s\"} %d\n", metricsPrefix, escapeLabel(tag),
+				t.Unix())
+		}
+	}
+
+	header("jobs_dropped_total", "counter", "Number of jobs dropped because the job channel was full.")
+	for _, tag := range m.tags {
+		fmt.Fprintf(w, "%sjobs_dropped_total{tag=\"%s\"} %d\n", metricsPrefix, escapeLabel(tag), m.dropped[tag])
+	}
+
+	header("backup_data_added_bytes", "gauge", "Bytes added to the repository by the last backup.")
+	for _, tag := range m.tags {
+		if v, ok := m.dataAdded[tag]; ok {
+			fmt.Fprintf(w, "%sbackup_data_added_bytes{tag=\"%s\"} %d\n", metricsPrefix, escapeLabel(tag), v)
+		}
+	}
+
+	header("backup_data_added_bytes_total", "counter", "Bytes added to the repository by all backups.")
+	for _, tag := range m.tags {
+		if v, ok := m.dataAddedTotal[tag]; ok {
+			fmt.Fprintf(w, "%sbackup_data_added_bytes_total{tag=\"%s\"} %d\n", metricsPrefix, escapeLabel(tag), v)
+		}
+	}
+
+	header("queue_depth", "gauge", "Number of jobs waiting to be processed.")
+	fmt.Fprintf(w, "%squeue_depth %d\n", metricsPrefix, queueDepth)
+}
+
+// handleMetrics exports the metrics of the scheduler in the Prometheus text format.
+func (s *scheduler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.write(w, len(s.jobChan))
+}
@@ -0,0 +1,68 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	m.register("backup")
+	m.register(`quoted "tag"`)
+	m.observe("backup", Result(Done), 3*time.Second, &BackupSummary{DataAdded: 1024})
+	m.observe("backup", Result(Done), 2*time.Second, &BackupSummary{DataAdded: 512})
+	m.observe("backup", Result(Error), 90*time.Second, nil)
+	m.drop("backup")
+
+	var b strings.Builder
+	m.write(&b, 2)
+	output := b.String()
+
+	expected := []string{
+		`restic_unattended_job_runs_total{tag="backup",result="done"} 2`,
+		`restic_unattended_job_runs_total{tag="backup",result="error"} 1`,
+		`restic_unattended_job_runs_total{tag="backup",result="fatal"} 0`,
+		`restic_unattended_job_runs_total{tag="quoted \"tag\"",result="done"} 0`,
+		`restic_unattended_job_duration_seconds_bucket{tag="backup",le="1"} 0`,
+		`restic_unattended_job_duration_seconds_bucket{tag="backup",le="5"} 2`,
+		`restic_unattended_job_duration_seconds_bucket{tag="backup",le="300"} 3`,
+		`restic_unattended_job_duration_seconds_bucket{tag="backup",le="+Inf"} 3`,
+		`restic_unattended_job_duration_seconds_sum{tag="backup"} 95`,
+		`restic_unattended_job_duration_seconds_count{tag="backup"} 3`,
+		`restic_unattended_job_last_success_timestamp_seconds{tag="backup"} `,
+		`restic_unattended_jobs_dropped_total{tag="backup"} 1`,
+		`restic_unattended_backup_data_added_bytes{tag="backup"} 512`,
+		`restic_unattended_backup_data_added_bytes_total{tag="backup"} 1536`,
+		`restic_unattended_queue_depth 2`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Metrics output is missing line: %s", want)
+		}
+	}
+
+	if strings.Contains(output, `job_last_success_timestamp_seconds{tag="quoted`) {
+		t.Errorf("Metrics output contains unexpected last success timestamp")
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	s := prepareScheduler()
+	s.process(*s.jobs[0])
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /metrics returned incorrect status code, got: %d, want: %d.", w.Code, http.StatusOK)
+	}
+	want := `restic_unattended_job_runs_total{tag="backup",result="error"} 1`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("GET /metrics is missing line: %s", want)
+	}
+}
@@ -0,0 +1,446 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// Defines the possible outcomes of a job, which can be used to select the events that trigger a notification.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeFatal   = "fatal"
+	OutcomeDropped = "dropped"
+)
+
+// defaultNotifyTimeout defines the maximum duration of a single notification, unless configured otherwise.
+const defaultNotifyTimeout = 30 * time.Second
+
+// defaultSubject and defaultBody define the default templates of a notification.
+const (
+	defaultSubject = "[restic-unattended] Job '{{.Tag}}' on {{.Hostname}}: {{.Outcome}}"
+	defaultBody    = "Job '{{.Tag}}' on {{.Hostname}} finished with outcome '{{.Outcome}}'." +
+		"{{if .Error}}\nError: {{.Error}}{{end}}\n"
+)
+
+// JobEvent describes the outcome of a scheduled job. It is passed to notifiers and is available to notification
+// templates, for example '{{.Tag}}' or '{{json .Error}}'.
+type JobEvent struct {
+	Tag      string         `json:"tag"`
+	Outcome  string         `json:"outcome"`
+	Hostname string         `json:"hostname"`
+	Start    *time.Time     `json:"start,omitempty"`
+	End      *time.Time     `json:"end,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Summary  *BackupSummary `json:"summary,omitempty"`
+}
+
+// NotificationConfig defines the settings of a single notification target, typically read from the config file. The
+// type is either 'webhook', 'smtp', or 'script'. Tags and Events restrict the jobs and outcomes that trigger the
+// notification, empty tags match all jobs. Events default to failure, fatal, and dropped. The Subject and Body are
+// Go templates rendered with a JobEvent. The webhook sends the body as JSON to the URL, defaulting to the JSON-encoded
+// event. The script receives the event as environment variables. Timeout limits the duration of each notification,
+// it defaults to 30 seconds.
+type NotificationConfig struct {
+	Type         string            `mapstructure:"type"`
+	Tags         []string          `mapstructure:"tags"`
+	Events       []string          `mapstructure:"events"`
+	Timeout      time.Duration     `mapstructure:"timeout"`
+	URL          string            `mapstructure:"url"`
+	Headers      map[string]string `mapstructure:"headers"`
+	Subject      string            `mapstructure:"subject"`
+	Body         string            `mapstructure:"body"`
+	Host         string            `mapstructure:"host"`
+	Port         int               `mapstructure:"port"`
+	Username     string            `mapstructure:"username"`
+	Password     string            `mapstructure:"password"`
+	PasswordFile string            `mapstructure:"password_file"`
+	From         string            `mapstructure:"from"`
+	To           []string          `mapstructure:"to"`
+	Command      string            `mapstructure:"command"`
+	Args         []string          `mapstructure:"args"`
+}
+
+// Notifier sends a notification about a job event to a specific target.
+type Notifier interface {
+	Notify(event JobEvent) error
+}
+
+// Notification combines a notifier with the tags and events that trigger it.
+type Notification struct {
+	name     string
+	notifier Notifier
+	tags     []string
+	events   []string
+}
+
+// webhookNotifier posts a JSON message to an HTTP endpoint.
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+	body    *template.Template
+	client  *http.Client
+}
+
+// smtpNotifier sends an email using an SMTP server.
+type smtpNotifier struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	subject  *template.Template
+	body     *template.Template
+	timeout  time.Duration
+}
+
+// scriptNotifier invokes a local command.
+type scriptNotifier struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// parseTemplate parses a notification template, using the default template if text is empty. Templates support the
+// function 'json' to encode a value as JSON, for example to escape an error message.
+func parseTemplate(name string, text string, defaultText string) (*template.Template, error) {
+	if text == "" {
+		text = defaultText
+	}
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+// render executes a template with the provided event.
+func render(t *template.Template, event JobEvent) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, event); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// newWebhookNotifier creates a notifier posting to an HTTP endpoint. The body defaults to the JSON-encoded event.
+func newWebhookNotifier(config NotificationConfig, timeout time.Duration) (*webhookNotifier, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("Webhook requires a URL")
+	}
+	body, err := parseTemplate("body", config.Body, "{{json .}}")
+	if err != nil {
+		return nil, err
+	}
+	return &webhookNotifier{
+		url:     config.URL,
+		headers: config.Headers,
+		body:    body,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// newSMTPNotifier creates a notifier sending an email. The password is read from PasswordFile if set.
+func newSMTPNotifier(config NotificationConfig, timeout time.Duration) (*smtpNotifier, error) {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("SMTP requires a host, sender, and at least one recipient")
+	}
+	port := config.Port
+	if port == 0 {
+		port = 25
+	}
+
+	password := config.Password
+	if config.PasswordFile != "" {
+		secret, err := readSecret(config.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read SMTP password file")
+		}
+		password = secret
+	}
+
+	subject, err := parseTemplate("subject", config.Subject, defaultSubject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate("body", config.Body, defaultBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return &smtpNotifier{
+		addr:     net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		host:     config.Host,
+		username: config.Username,
+		password: password,
+		from:     config.From,
+		to:       config.To,
+		subject:  subject,
+		body:     body,
+		timeout:  timeout,
+	}, nil
+}
+
+// newScriptNotifier creates a notifier invoking a local command.
+func newScriptNotifier(config NotificationConfig, timeout time.Duration) (*scriptNotifier, error) {
+	if config.Command == "" {
+		return nil, fmt.Errorf("Script requires a command")
+	}
+	return &scriptNotifier{command: config.Command, args: config.Args, timeout: timeout}, nil
+}
+
+// matches validates if the notification is triggered by the provided event. A tag also matches the jobs of a backup
+// set or repository profile derived from it, see matchesTag.
+func (n *Notification) matches(event JobEvent) bool {
+	if len(n.tags) > 0 && !matchesTag(n.tags, event.Tag) {
+		return false
+	}
+	return Contains(n.events, event.Outcome)
+}
+
+// notify sends the event to all matching notifications. Errors are written to the logger.
+func (s *scheduler) notify(event JobEvent) {
+	for _, n := range s.notifications {
+		if !n.matches(event) {
+			continue
+		}
+		Logger.Debug().Msgf("Sending %s notification for job '%s'", n.name, event.Tag)
+		if err := n.notifier.Notify(event); err != nil {
+			Logger.Error().Err(err).Msgf("Could not send %s notification for job '%s'", n.name, event.Tag)
+		}
+	}
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// NewJobEvent creates an event for a job with a specific outcome. The start and end time, error, and summary are
+// optional.
+func NewJobEvent(tag string, outcome string, start time.Time, end time.Time, err error,
+	summary *BackupSummary) JobEvent {
+
+	event := JobEvent{Tag: tag, Outcome: outcome, Summary: summary}
+	event.Hostname, _ = os.Hostname()
+	if !start.IsZero() {
+		event.Start = &start
+	}
+	if !end.IsZero() {
+		event.End = &end
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// NewNotification creates a notification from its configuration. It returns an error if the configuration is
+// invalid.
+func NewNotification(config NotificationConfig) (*Notification, error) {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultNotifyTimeout
+	}
+
+	events := config.Events
+	if len(events) == 0 {
+		events = []string{OutcomeFailure, OutcomeFatal, OutcomeDropped}
+	}
+	for _, e := range events {
+		if !Contains([]string{OutcomeSuccess, OutcomeFailure, OutcomeFatal, OutcomeDropped}, e) {
+			return nil, fmt.Errorf("Unknown notification event '%s'", e)
+		}
+	}
+
+	var notifier Notifier
+	var err error
+	switch config.Type {
+	case "webhook":
+		notifier, err = newWebhookNotifier(config, timeout)
+	case "smtp":
+		notifier, err = newSMTPNotifier(config, timeout)
+	case "script":
+		notifier, err = newScriptNotifier(config, timeout)
+	default:
+		return nil, fmt.Errorf("Unknown notification type '%s'", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Notification{name: config.Type, notifier: notifier, tags: config.Tags, events: events}, nil
+}
+
+// NewNotifications creates notifications from a list of configurations. It returns an error if any of the
+// configurations is invalid.
+func NewNotifications(configs []NotificationConfig) ([]*Notification, error) {
+	notifications := []*Notification{}
+	for i, config := range configs {
+		n, err := NewNotification(config)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid notification %d: %s", i+1, err.Error())
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// Notify posts the rendered body to the webhook URL. It returns an error if the endpoint does not respond with a
+// 2xx status code.
+func (w *webhookNotifier) Notify(event JobEvent) error {
+	body, err := render(w.body, event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// send delivers the message to the SMTP server, similar to smtp.SendMail. The connection is closed if the exchange
+// exceeds the timeout of the notifier, as an unresponsive server would otherwise block the scheduler indefinitely.
+// STARTTLS is used if the server supports it.
+func (m *smtpNotifier) send(auth smtp.Auth, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP server does not support authentication")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Notify sends the rendered subject and body as plain-text email. Authentication is used if a username is set. The
+// email is abandoned if the SMTP server does not respond within the timeout.
+func (m *smtpNotifier) Notify(event JobEvent) error {
+	subject, err := render(m.subject, event)
+	if err != nil {
+		return err
+	}
+	body, err := render(m.body, event)
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return m.send(auth, msg.Bytes())
+}
+
+// Notify invokes the script with the event exposed as environment variables RESTIC_JOB_TAG, RESTIC_JOB_OUTCOME,
+// RESTIC_JOB_ERROR, and RESTIC_JOB_EVENT (JSON-encoded). The output of the script is written to the logger. The
+// script is killed if it exceeds the timeout.
+func (s *scriptNotifier) Notify(event JobEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	Logger.Debug().Msgf("Executing command: %s %s", s.command, s.args)
+	cmd := exec.CommandContext(ctx, s.command, s.args...)
+	cmd.Env = append(os.Environ(),
+		"RESTIC_JOB_TAG="+event.Tag,
+		"RESTIC_JOB_OUTCOME="+event.Outcome,
+		"RESTIC_JOB_ERROR="+event.Error,
+		"RESTIC_JOB_EVENT="+string(data),
+	)
+	cmd.Stdout = NewLogWriter(&Logger, zerolog.InfoLevel)
+	cmd.Stderr = NewLogWriter(&Logger, zerolog.ErrorLevel)
+	return cmd.Run()
+}
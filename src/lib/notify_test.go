@@ -0,0 +1,257 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// serveSMTP runs a minimal SMTP stand-in accepting a single message. The message data is sent to the returned
+// channel.
+func serveSMTP(t *testing.T) (string, int, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start SMTP stand-in: %s", err.Error())
+	}
+	data := make(chan string, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				data <- msg.String()
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, data
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestNewNotification(t *testing.T) {
+	tables := []struct {
+		config  NotificationConfig
+		isValid bool
+	}{
+		{NotificationConfig{Type: "webhook", URL: "http://localhost"}, true},
+		{NotificationConfig{Type: "webhook"}, false},
+		{NotificationConfig{Type: "webhook", URL: "http://localhost", Body: "{{.Tag"}, false},
+		{NotificationConfig{Type: "smtp", Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}},
+			true},
+		{NotificationConfig{Type: "smtp", Host: "localhost"}, false},
+		{NotificationConfig{Type: "script", Command: "/bin/true"}, true},
+		{NotificationConfig{Type: "script", Command: "/bin/true", Events: []string{"unknown"}}, false},
+		{NotificationConfig{Type: "unknown"}, false},
+	}
+
+	for i, table := range tables {
+		_, err := NewNotification(table.config)
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("NewNotification %d was incorrect, got: %t, want: %t.", i+1, isValid, table.isValid)
+		}
+	}
+}
+
+func TestNotificationMatches(t *testing.T) {
+	n, err := NewNotification(NotificationConfig{Type: "script", Command: "/bin/true", Tags: []string{"backup"}})
+	if err != nil {
+		t.Errorf("NewNotification returned an error: %s.", err.Error())
+		return
+	}
+
+	tables := []struct {
+		tag     string
+		outcome string
+		matches bool
+	}{
+		{"backup", OutcomeFailure, true},
+		{"backup", OutcomeFatal, true},
+		{"backup", OutcomeDropped, true},
+		{"backup", OutcomeSuccess, false},
+		{"backup-db", OutcomeFailure, true},
+		{"backup@b2", OutcomeFatal, true},
+		{"backups", OutcomeFailure, false},
+		{"forget", OutcomeFailure, false},
+	}
+	for _, table := range tables {
+		event := JobEvent{Tag: table.tag, Outcome: table.outcome}
+		if matches := n.matches(event); matches != table.matches {
+			t.Errorf("Notification matches '%s/%s' was incorrect, got: %t, want: %t.", table.tag, table.outcome,
+				matches, table.matches)
+		}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	body := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body <- r.Header.Get("X-Token") + " " + string(b)
+	}))
+	defer srv.Close()
+
+	s := prepareScheduler()
+	n, err := NewNotification(NotificationConfig{
+		Type:    "webhook",
+		URL:     srv.URL,
+		Headers: map[string]string{"X-Token": "secret"},
+		Body:    `{"text": "{{.Tag}}: {{.Outcome}}", "error": {{json .Error}}}`,
+	})
+	if err != nil {
+		t.Errorf("NewNotification returned an error: %s.", err.Error())
+		return
+	}
+	s.notifications = []*Notification{n}
+	s.process(*s.jobs[0])
+
+	want := `secret {"text": "backup: failure", "error": "backup failed"}`
+	select {
+	case got := <-body:
+		if got != want {
+			t.Errorf("Webhook received incorrect body, got: %s, want: %s.", got, want)
+		}
+	default:
+		t.Errorf("Webhook did not receive a notification")
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	host, port, data := serveSMTP(t)
+
+	n, err := NewNotification(NotificationConfig{
+		Type: "smtp",
+		Host: host,
+		Port: port,
+		From: "alerts@example.com",
+		To:   []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Errorf("NewNotification returned an error: %s.", err.Error())
+		return
+	}
+	event := NewJobEvent("backup", OutcomeFatal, time.Now(), time.Now(), errors.New("disk full"), nil)
+	if err := n.notifier.Notify(event); err != nil {
+		t.Errorf("SMTP notifier returned an error: %s.", err.Error())
+		return
+	}
+
+	msg := <-data
+	for _, want := range []string{"To: ops@example.com", "Subject: [restic-unattended] Job 'backup' on ", ": fatal",
+		"Error: disk full"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("SMTP notifier sent message without '%s'", want)
+		}
+	}
+}
+
+func TestSMTPNotifierTimeout(t *testing.T) {
+	// the stand-in accepts the connection, but never replies
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start SMTP stand-in: %s", err.Error())
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	n, err := NewNotification(NotificationConfig{Type: "smtp", Host: addr.IP.String(), Port: addr.Port,
+		From: "alerts@example.com", To: []string{"ops@example.com"}, Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewNotification returned an error: %s.", err.Error())
+	}
+
+	start := time.Now()
+	event := NewJobEvent("backup", OutcomeFailure, time.Now(), time.Now(), nil, nil)
+	if err := n.notifier.Notify(event); err == nil {
+		t.Errorf("SMTP notifier did not return an error for an unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SMTP notifier did not respect the timeout, got duration: %s.", elapsed)
+	}
+}
+
+func TestScriptNotifier(t *testing.T) {
+	dir := t.TempDir()
+	script := path.Join(dir, "notify.sh")
+	output := path.Join(dir, "output")
+	content := "#!/bin/sh\necho \"$RESTIC_JOB_TAG $RESTIC_JOB_OUTCOME $RESTIC_JOB_ERROR\" > \"$1\"\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Errorf("Could not write script: %s.", err.Error())
+		return
+	}
+
+	n, err := NewNotification(NotificationConfig{Type: "script", Command: script, Args: []string{output}})
+	if err != nil {
+		t.Errorf("NewNotification returned an error: %s.", err.Error())
+		return
+	}
+	event := NewJobEvent("forget", OutcomeFailure, time.Time{}, time.Time{}, errors.New("locked"), nil)
+	if err := n.notifier.Notify(event); err != nil {
+		t.Errorf("Script notifier returned an error: %s.", err.Error())
+		return
+	}
+
+	got, err := ReadLine(output)
+	if err != nil {
+		t.Errorf("Script notifier did not write output: %s.", err.Error())
+	} else if got != "forget failure locked" {
+		t.Errorf("Script notifier received incorrect event, got: %s, want: %s.", got, "forget failure locked")
+	}
+}
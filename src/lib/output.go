@@ -0,0 +1,105 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// OutputFormat defines the type of rendering to use for command results, such as a list of snapshots.
+type OutputFormat int
+
+// Defines a pseudo enumeration of possible output formats.
+const (
+	// TableOutput renders results as a simple aligned/padded ASCII table.
+	TableOutput OutputFormat = iota
+	// JSONOutput renders results as indented JSON.
+	JSONOutput
+	// YAMLOutput renders results as YAML.
+	YAMLOutput
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// LogLines writes each line of the provided text to the logger using info level.
+func LogLines(text string) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		Logger.Info().Msg(scanner.Text())
+	}
+}
+
+// ParseOutputFormat converts a format string into a typed output format value. It returns an error if the input
+// string does not match known values.
+func ParseOutputFormat(formatStr string) (OutputFormat, error) {
+	switch formatStr {
+	case "table":
+		return OutputFormat(TableOutput), nil
+	case "json":
+		return OutputFormat(JSONOutput), nil
+	case "yaml":
+		return OutputFormat(YAMLOutput), nil
+	}
+	return OutputFormat(TableOutput), fmt.Errorf("Unknown output format: '%s'", formatStr)
+}
+
+// Render converts a result to a string using the provided output format. Table output is generated from the header
+// and rows, whilst JSON and YAML output are generated by marshalling v.
+func Render(format OutputFormat, header []string, rows [][]string, v interface{}) (string, error) {
+	switch format {
+	case OutputFormat(JSONOutput):
+		bytes, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	case OutputFormat(YAMLOutput):
+		bytes, err := yaml.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	default:
+		return RenderTable(header, rows), nil
+	}
+}
+
+// RenderTable renders a simple aligned/padded ASCII table as string. The table has no borders or separators.
+func RenderTable(header []string, rows [][]string) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader(header)
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("  ") // pad with spaces
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(rows) // add Bulk Data
+	table.Render()
+
+	return tableString.String()
+}
+
+// String converts a typed output format to it's string representation.
+func (format OutputFormat) String() string {
+	return [...]string{"table", "json", "yaml"}[format]
+}
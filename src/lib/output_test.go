@@ -0,0 +1,53 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, format := range []OutputFormat{TableOutput, JSONOutput, YAMLOutput} {
+		parsed, err := ParseOutputFormat(format.String())
+		if err != nil {
+			t.Errorf("ParseOutputFormat returned an error: %s.", err.Error())
+		} else if parsed != format {
+			t.Errorf("ParseOutputFormat was incorrect, got: %s, want: %s.", parsed, format)
+		}
+	}
+
+	if _, err := ParseOutputFormat("xml"); err == nil {
+		t.Errorf("ParseOutputFormat returned unexpected result, got: nil, want: error")
+	}
+}
+
+func TestRender(t *testing.T) {
+	header := []string{"Key", "Value"}
+	v := map[string]string{"Key 1": "Value 1"}
+	tables := []struct {
+		format OutputFormat
+		want   string
+	}{
+		{TableOutput, "KEY    VALUE\nKey 1  Value 1\n"},
+		{JSONOutput, "{\n  \"Key 1\": \"Value 1\"\n}"},
+		{YAMLOutput, "Key 1: Value 1\n"},
+	}
+
+	for _, table := range tables {
+		got, err := Render(table.format, header, inputArray[:1], v)
+		if err != nil {
+			t.Errorf("Render returned an error: %s.", err.Error())
+			continue
+		}
+		// remove trailing whitespace of table padding
+		lines := strings.Split(got, "\n")
+		for i := range lines {
+			lines[i] = strings.TrimRight(lines[i], " ")
+		}
+		if got = strings.Join(lines, "\n"); got != table.want {
+			t.Errorf("Render with '%s' format was incorrect, got: %q, want: %q.", table.format, got, table.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// defaultPingTimeout defines the maximum duration of a single ping, unless configured otherwise.
+const defaultPingTimeout = 10 * time.Second
+
+// defaultPingLogLines defines the number of log lines included in a failure ping, unless configured otherwise.
+const defaultPingLogLines = 20
+
+// PingConfig defines the URLs to ping when a job starts, succeeds, or fails. This follows the dead man's switch
+// pattern of monitoring services such as healthchecks.io and Uptime Kuma, which raise an alert if an expected ping
+// does not arrive in time. Empty URLs are skipped. The failure ping includes the exit status of the job and its
+// last log messages, limited by LogLines (defaults to 20). Method defaults to POST.
+type PingConfig struct {
+	Start    string        `mapstructure:"start"`
+	Success  string        `mapstructure:"success"`
+	Failure  string        `mapstructure:"failure"`
+	Method   string        `mapstructure:"method"`
+	LogLines int           `mapstructure:"log_lines"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// ping sends a request with the provided body to the URL, unless the URL is empty. Errors are written to the logger,
+// as a failing monitoring service should not affect the job itself.
+func (p *PingConfig) ping(tag string, url string, body string) {
+	if url == "" {
+		return
+	}
+
+	method := p.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = defaultPingTimeout
+	}
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		var resp *http.Response
+		client := &http.Client{Timeout: timeout}
+		if resp, err = client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("Ping returned status %d", resp.StatusCode)
+			}
+		}
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msgf("Could not ping monitoring service for job '%s'", tag)
+	}
+}
+
+// wrap returns a function that invokes run and pings the configured URLs before and after. The failure body consists
+// of the exit status, the error, and the last log messages of the job.
+func (p *PingConfig) wrap(tag string, run func() (*BackupSummary, error)) func() (*BackupSummary, error) {
+	return func() (*BackupSummary, error) {
+		p.ping(tag, p.Start, fmt.Sprintf("Job '%s' started", tag))
+		mark := MarkLogs()
+
+		summary, err := run()
+		if err == nil {
+			p.ping(tag, p.Success, fmt.Sprintf("Job '%s' succeeded", tag))
+			return summary, nil
+		}
+
+		lines := p.LogLines
+		if lines == 0 {
+			lines = defaultPingLogLines
+		}
+		body := fmt.Sprintf("Job '%s' failed with exit status %d: %s\n\n%s\n", tag, ExitStatus(err), err.Error(),
+			strings.Join(TailLogs(mark, lines), "\n"))
+		p.ping(tag, p.Failure, body)
+		return summary, err
+	}
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// ExitStatus returns the exit status associated with an error. It returns the exit code of the external command if
+// err is caused by a failing command, 0 if err is nil, or 1 otherwise.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
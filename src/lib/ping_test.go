@@ -0,0 +1,127 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestExitStatus(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	tables := []struct {
+		err    error
+		status int
+	}{
+		{nil, 0},
+		{errors.New("failed"), 1},
+		{exitErr, 3},
+		{&ResticError{Err: "Could not complete prune operation", Cause: exitErr}, 3},
+	}
+
+	for i, table := range tables {
+		if status := ExitStatus(table.err); status != table.status {
+			t.Errorf("ExitStatus %d was incorrect, got: %d, want: %d.", i+1, status, table.status)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		pings = append(pings, r.URL.Path+" "+string(b))
+	}))
+	defer srv.Close()
+
+	InitLogger(Default)
+	s := prepareScheduler()
+	job := s.jobs[0]
+	job.Ping = &PingConfig{Start: srv.URL + "/start", Success: srv.URL + "/success", Failure: srv.URL + "/fail",
+		LogLines: 1}
+	job.RunE = func(ctx context.Context) error {
+		Logger.Info().Msg("first message")
+		Logger.Error().Msg("last message")
+		return errors.New("backup failed")
+	}
+	s.process(*job)
+
+	job.RunE = func(ctx context.Context) error { return nil }
+	s.process(*job)
+
+	want := []string{
+		"/start Job 'backup' started",
+		"/fail Job 'backup' failed with exit status 1: backup failed\n\nERROR last message\n",
+		"/start Job 'backup' started",
+		"/success Job 'backup' succeeded",
+	}
+	if len(pings) != len(want) {
+		t.Errorf("Ping received incorrect number of requests, got: %d, want: %d.", len(pings), len(want))
+		return
+	}
+	for i := range want {
+		if pings[i] != want[i] {
+			t.Errorf("Ping %d was incorrect, got: %s, want: %s.", i+1, strings.TrimSpace(pings[i]), want[i])
+		}
+	}
+}
+
+func TestPingExitStatus(t *testing.T) {
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		pings = append(pings, string(b))
+	}))
+	defer srv.Close()
+
+	// the fake restic binary fails the forget command with exit code 3
+	restic := path.Join(t.TempDir(), "restic")
+	if err := os.WriteFile(restic, []byte("#!/bin/sh\n[ \"$1\" = \"forget\" ] && exit 3\nexit 0\n"), 0755); err != nil {
+		t.Fatalf("Cannot write fake restic: %s", err.Error())
+	}
+	r := NewResticManagerWithContext(restic, nil)
+	jobs, err := r.Jobs(ScheduleOptions{ForgetCron: "@daily",
+		Pings: map[string]*PingConfig{"forget": {Failure: srv.URL}}})
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("Jobs returned unexpected result, got: %v, %v.", jobs, err)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	s := newScheduler(jobs, CronOptions{})
+	s.process(*s.jobs[0])
+
+	want := "Job 'forget' failed with exit status 3: Could not complete forget operation"
+	if len(pings) != 1 || !strings.HasPrefix(pings[0], want) {
+		t.Errorf("Ping reported incorrect exit status, got: %v, want prefix: %s.", pings, want)
+	}
+}
+
+func TestPingFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := prepareScheduler()
+	job := s.jobs[0]
+	job.Ping = &PingConfig{Start: srv.URL, Failure: srv.URL}
+	if result, _ := s.process(*job); result != Result(Error) {
+		t.Errorf("Failing ping changed job result, got: %s, want: %s.", result, Result(Error))
+	}
+}
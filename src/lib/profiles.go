@@ -0,0 +1,171 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// AllProfiles is a reserved profile name that selects all repository profiles.
+const AllProfiles = "all"
+
+// RepositoryProfile defines a named repository with its own credentials. The repository location and password can be
+// provided directly, or as file-based secret (e.g. a Docker secret). Env defines additional environment variables,
+// such as backend credentials. Env supports the same file-based secrets as the process environment, identified by
+// their '_FILE' suffix. The variables of a profile take precedence over the process environment.
+type RepositoryProfile struct {
+	Name           string            `mapstructure:"name"`
+	Repository     string            `mapstructure:"repository"`
+	RepositoryFile string            `mapstructure:"repository_file"`
+	Password       string            `mapstructure:"password"`
+	PasswordFile   string            `mapstructure:"password_file"`
+	Env            map[string]string `mapstructure:"env"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// vars returns the environment variables defined by the profile. All keys are converted to upper case.
+func (p RepositoryProfile) vars() map[string]string {
+	vars := map[string]string{}
+	for key, value := range p.Env {
+		vars[strings.ToUpper(key)] = value
+	}
+
+	set := func(key string, value string) {
+		if value != "" {
+			vars[key] = value
+		}
+	}
+	set("RESTIC_REPOSITORY", p.Repository)
+	set("RESTIC_REPOSITORY_FILE", p.RepositoryFile)
+	set("RESTIC_PASSWORD", p.Password)
+	set("RESTIC_PASSWORD_FILE", p.PasswordFile)
+	return vars
+}
+
+// envMap returns a function that retrieves the environment variables of base, overridden by the variables of the
+// profile. A profile variable replaces both its regular and file-based counterpart in base. For example, the profile
+// variable RESTIC_PASSWORD_FILE discards both RESTIC_PASSWORD and RESTIC_PASSWORD_FILE from base.
+func (p RepositoryProfile) envMap(base EnvMap) EnvMap {
+	return func(folder string) map[string]string {
+		env := base(folder)
+		vars := p.vars()
+		for key := range vars {
+			key = strings.TrimSuffix(key, "_FILE")
+			delete(env, key)
+			delete(env, key+"_FILE")
+		}
+		for key, value := range vars {
+			env[key] = value
+		}
+		return env
+	}
+}
+
+// resolveProfiles returns the profile names identified by names, where 'all' selects all available names sorted
+// alphabetically. It returns an error if a name is not available.
+func resolveProfiles(available []string, names []string) ([]string, error) {
+	if Contains(names, AllProfiles) {
+		if len(available) == 0 {
+			return nil, errors.New("No repository profiles defined")
+		}
+		names = append([]string{}, available...)
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		if !Contains(available, name) {
+			return nil, fmt.Errorf("Unknown repository profile '%s'", name)
+		}
+	}
+	return names, nil
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// NewResticManagerWithProfile creates a new restic manager targeting the repository of the provided profile. The
+// variables of the profile are merged with the current environment, and any file-based secrets are staged.
+func NewResticManagerWithProfile(p RepositoryProfile) (*ResticManager, error) {
+	m := NewSecretsManagerWithEnv(p.envMap(getEnvMap), "")
+	env, err := m.StageEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid repository profile '%s': %s", p.Name, err.Error())
+	}
+
+	return &ResticManager{cmd: "restic", env: env, profile: p.Name}, nil
+}
+
+// NewResticManagers creates a restic manager for each repository profile selected by names, see SelectProfiles. It
+// returns a single manager targeting the repository of the current environment if names is empty.
+func NewResticManagers(profiles []RepositoryProfile, names []string) ([]*ResticManager, error) {
+	if len(names) == 0 {
+		r, err := NewResticManager()
+		if err != nil {
+			return nil, err
+		}
+		return []*ResticManager{r}, nil
+	}
+
+	selected, err := SelectProfiles(profiles, names)
+	if err != nil {
+		return nil, err
+	}
+	managers := []*ResticManager{}
+	for _, p := range selected {
+		r, err := NewResticManagerWithProfile(p)
+		if err != nil {
+			return nil, err
+		}
+		managers = append(managers, r)
+	}
+	return managers, nil
+}
+
+// SelectProfiles returns the repository profiles identified by names, in the order of names. The name 'all' selects
+// all profiles sorted by name. It returns an error if a name cannot be found.
+func SelectProfiles(profiles []RepositoryProfile, names []string) ([]RepositoryProfile, error) {
+	index := map[string]RepositoryProfile{}
+	available := []string{}
+	for _, p := range profiles {
+		index[p.Name] = p
+		available = append(available, p.Name)
+	}
+
+	names, err := resolveProfiles(available, names)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []RepositoryProfile{}
+	for _, name := range names {
+		selected = append(selected, index[name])
+	}
+	return selected, nil
+}
+
+// ValidateProfiles ensures all repository profiles have a valid and unique name.
+func ValidateProfiles(profiles []RepositoryProfile) error {
+	names := map[string]bool{}
+	for _, p := range profiles {
+		if !namePattern.MatchString(p.Name) || p.Name == AllProfiles {
+			return fmt.Errorf("Invalid repository profile name '%s'", p.Name)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("Duplicate repository profile name '%s'", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestProfileEnv(t *testing.T) {
+	folder := t.TempDir()
+	secret := path.Join(folder, "B2_PASSWORD")
+	if err := WriteLine(secret, "b2-secret"); err != nil {
+		t.Errorf("Could not write secret: %s.", err.Error())
+		return
+	}
+
+	base := func(folder string) map[string]string {
+		return map[string]string{
+			"RESTIC_REPOSITORY": "default",
+			"RESTIC_PASSWORD":   "default-secret",
+			"CUSTOM":            "CUSTOM",
+		}
+	}
+	p := RepositoryProfile{
+		Name:         "b2",
+		Repository:   "b2:bucket",
+		PasswordFile: secret,
+		Env:          map[string]string{"b2_account_id": "ID"},
+	}
+
+	m := NewSecretsManagerWithEnv(p.envMap(base), folder)
+	got, err := m.StageEnv()
+	if err != nil {
+		t.Errorf("StageEnv returned an error: %s.", err.Error())
+		return
+	}
+	sort.Strings(got)
+	want := []string{"B2_ACCOUNT_ID=ID", "CUSTOM=CUSTOM", "RESTIC_PASSWORD=b2-secret", "RESTIC_REPOSITORY=b2:bucket"}
+	if !Equal(got, want) {
+		t.Errorf("Profile returned incorrect environment, got: %v, want: %v.", got, want)
+	}
+}
+
+func TestSelectProfiles(t *testing.T) {
+	profiles := []RepositoryProfile{{Name: "nas"}, {Name: "b2"}}
+
+	tables := []struct {
+		names   []string
+		want    string
+		isValid bool
+	}{
+		{[]string{}, "", true},
+		{[]string{"nas"}, "nas", true},
+		{[]string{"nas", "b2"}, "nas,b2", true},
+		{[]string{"all"}, "b2,nas", true},
+		{[]string{"unknown"}, "", false},
+	}
+
+	for _, table := range tables {
+		selected, err := SelectProfiles(profiles, table.names)
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("SelectProfiles %v was incorrect, got: %t, want: %t.", table.names, isValid, table.isValid)
+			continue
+		}
+		names := []string{}
+		for _, p := range selected {
+			names = append(names, p.Name)
+		}
+		if got := strings.Join(names, ","); table.isValid && got != table.want {
+			t.Errorf("SelectProfiles %v returned incorrect profiles, got: %s, want: %s.", table.names, got, table.want)
+		}
+	}
+
+	if _, err := SelectProfiles(nil, []string{"all"}); err == nil {
+		t.Errorf("SelectProfiles without profiles returned unexpected result, got: nil, want: error")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tables := []struct {
+		profiles []RepositoryProfile
+		isValid  bool
+	}{
+		{[]RepositoryProfile{{Name: "nas"}, {Name: "b2"}}, true},
+		{[]RepositoryProfile{{Name: "nas"}, {Name: "nas"}}, false},
+		{[]RepositoryProfile{{Name: "all"}}, false},
+		{[]RepositoryProfile{{Name: ""}}, false},
+	}
+
+	for i, table := range tables {
+		err := ValidateProfiles(table.profiles)
+		if isValid := err == nil; isValid != table.isValid {
+			t.Errorf("ValidateProfiles %d was incorrect, got: %t, want: %t.", i+1, isValid, table.isValid)
+		}
+	}
+}
@@ -4,23 +4,168 @@
 package lib
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
-// ResticManager manages the invocation of the external binary restic.
+// ResticManager manages the invocation of the external binary restic. Invoked commands are interrupted when the
+// context of the manager is done, see WithContext.
 type ResticManager struct {
-	cmd string
-	env []string
+	cmd     string
+	env     []string
+	profile string
+	ctx     context.Context
+}
+
+// KillGracePeriod defines how long an interrupted command may take to terminate gracefully, after which it is killed.
+var KillGracePeriod = 30 * time.Second
+
+// BackupOptions defines the options of ResticManager.BackupWithOptions. Paths defines the local paths to backup,
+// Excludes the patterns of files and directories to exclude, and Tags the tags to add to the new snapshot. Flags holds
+// additional flags relayed to the backup command, such as '--exclude-caches' or '--one-file-system'. The
+// repository is initialized if Init is set and the repository does not exist yet. Hooks optionally defines the
+// commands to run before and after the backup. Docker optionally defines the containers to stop during the backup.
+// Stdin optionally defines a command of which the output is backed up instead of the paths.
+type BackupOptions struct {
+	Paths    []string
+	Excludes []string
+	Flags    []string
+	Tags     []string
+	Host     string
+	Init     bool
+	Hooks    *Hooks
+	Docker   *DockerOptions
+	Stdin    *StdinSource
+}
+
+// RestoreOptions defines the options of ResticManager.RestoreWithOptions. Snapshot defines the ID of the snapshot to
+// restore, 'latest' selects the most recent snapshot matching Filter. Target defines the local path to restore to.
+// Includes and Excludes define the patterns of files and directories to restore or skip respectively, which allows to
+// restore a single file without retrieving the entire snapshot. The restored files are verified if Verify is set.
+type RestoreOptions struct {
+	Snapshot string
+	Target   string
+	Filter   SnapshotFilter
+	Includes []string
+	Excludes []string
+	Verify   bool
+}
+
+// StdinSource defines a shell command of which the output is streamed into a snapshot, e.g. a database dump. The
+// command is invoked with '/bin/sh -c', restic stores its output as a single file named Filename (defaults to
+// 'stdin'). This avoids writing the output to disk before it is backed up.
+type StdinSource struct {
+	Command  string `mapstructure:"command"`
+	Filename string `mapstructure:"filename"`
+}
+
+// ScheduleOptions defines the jobs to be scheduled by ResticManager.Schedule. A job is skipped if its cron
+// specification is empty.
+type ScheduleOptions struct {
+	// BackupCron, ForgetCron, and CopyCron define the cron specification of the backup, forget, and copy job.
+	BackupCron string
+	ForgetCron string
+	CopyCron   string
+
+	// CheckCron defines the schedule of the check job, which verifies the data subset CheckSubset (see
+	// ResticManager.CheckSubset).
+	CheckCron   string
+	CheckSubset string
+
+	// PruneCron defines the schedule of the prune job, PruneFlags holds the flags relayed to the prune command. Forget
+	// jobs only prune the repository themselves if no prune job is scheduled.
+	PruneCron  string
+	PruneFlags []string
+
+	// RestoreTestCron defines the schedule of the restore test job, RestoreTest holds its options.
+	RestoreTestCron string
+	RestoreTest     RestoreTestOptions
+
+	// StateDir defines the directory to persist state and the job history across restarts.
+	StateDir string
+
+	// Paths, BackupFlags, Init, and Host define the backup job that is not part of a backup set. BackupFlags holds the
+	// flags relayed to its backup command.
+	Paths       []string
+	BackupFlags []string
+	Init        bool
+	Host        string
+
+	// Sustained keeps processing the jobs if a job returns an error, see CronOptions.HaltOnError.
+	Sustained bool
+
+	// KeepFlags holds the keep-* flags relayed to the forget command.
+	KeepFlags []string
+
+	// Listen defines the address of the optional HTTP status and control API.
+	Listen string
+
+	// StatusFile defines the path of the status file that is updated after each job.
+	StatusFile string
+
+	// Notifications are sent when a job finishes or is dropped.
+	Notifications []*Notification
+
+	// Pings defines the monitoring URLs of each job, identified by tag.
+	Pings map[string]*PingConfig
+
+	// Sets defines additional backup sets, each scheduled as separate backup and forget jobs.
+	Sets []BackupSet
+
+	// Repositories defines the restic managers of the available repository profiles by name.
+	Repositories map[string]*ResticManager
+
+	// Profiles selects the repository profiles targeted by the jobs that are not part of a backup set. The jobs target
+	// the repository of the manager itself if no profiles are selected.
+	Profiles []string
+
+	// Hooks defines the commands to run before and after each backup job that is not part of a backup set.
+	Hooks *Hooks
+
+	// Docker defines the containers to stop during the backup jobs that are not part of a backup set.
+	Docker *DockerOptions
+
+	// Stdin optionally defines a command of which the output is backed up by the backup job instead of Paths.
+	Stdin *StdinSource
+
+	// Diff compares the new snapshot of each backup job with the previous one, the differences are logged and included
+	// in the job summary.
+	Diff bool
+
+	// Timeout defines the maximum duration of each job, Timeouts overrides it by tag. A job is interrupted once it
+	// exceeds its timeout, a timeout of zero disables the limit.
+	Timeout  time.Duration
+	Timeouts map[string]time.Duration
+
+	// DrainPeriod defines how long a running job may take to finish once the scheduler is terminated.
+	DrainPeriod time.Duration
+
+	// Reload optionally rebuilds the jobs on SIGHUP, typically using Jobs with updated options. Watch optionally
+	// triggers a reload too, see CronOptions.
+	Reload func() ([]Job, error)
+	Watch  func(reload func())
+
+	// Catchup defines the policy for runs missed while the scheduler was not running, Catchups overrides it by tag.
+	// Missed runs are detected using the job history in StateDir.
+	Catchup  CatchupPolicy
+	Catchups map[string]CatchupPolicy
 }
 
 // ResticError defines a custom error for failed execution of restic commands.
 type ResticError struct {
 	Err   string // error description
 	Fatal bool   // fatal or non-fatal error
+	Cause error  // underlying error, if any
 }
 
 //======================================================================================================================
@@ -31,17 +176,44 @@ func (e *ResticError) Error() string {
 	return e.Err
 }
 
+// Unwrap returns the underlying error, which allows errors.As to retrieve the exit status of a failed command.
+func (e *ResticError) Unwrap() error {
+	return e.Cause
+}
+
 // ExecuteCmd invokes an external command with the provided arguments and environment variables. Pending if log is true,
-// all output of the command (both stdout and stderr) is logged in real time. Otherwise, only errors are logged.
-func ExecuteCmd(env []string, log bool, command string, args ...string) error {
+// all output of the command (both stdout and stderr) is logged in real time. Otherwise, only errors are logged. The
+// command is interrupted when ctx is done, see ExecuteCmdWithIO.
+func ExecuteCmd(ctx context.Context, env []string, log bool, command string, args ...string) error {
+	// redirect stdout to the default logger if instructed
+	var stdout io.Writer
+	if log {
+		stdout = NewLogWriter(&Logger, zerolog.InfoLevel)
+	}
+	return ExecuteCmdWithWriter(ctx, env, stdout, command, args...)
+}
+
+// ExecuteCmdWithWriter invokes an external command with the provided arguments and environment variables. The stdout
+// of the command is written to the provided writer, if any. Errors (stderr) are logged in real time.
+func ExecuteCmdWithWriter(ctx context.Context, env []string, stdout io.Writer, command string, args ...string) error {
+	return ExecuteCmdWithIO(ctx, env, nil, stdout, command, args...)
+}
+
+// ExecuteCmdWithIO invokes an external command with the provided arguments and environment variables. The stdin of
+// the command is read from the provided reader, if any. See ExecuteCmdWithWriter for more details. When ctx is done,
+// the command receives SIGINT and is killed if it does not terminate within KillGracePeriod. The error of ctx is
+// returned in that case.
+func ExecuteCmdWithIO(ctx context.Context, env []string, stdin io.Reader, stdout io.Writer, command string,
+	args ...string) error {
 	// initiate the command with current environment and secrets
 	Logger.Debug().Msgf("Executing command: %s %s", command, args)
 	cmd := exec.Command(command, args...)
 	cmd.Env = env
+	cmd.Stdin = stdin
 
-	// redirect stdout and stderr to the default logger if instructed
-	if log {
-		cmd.Stdout = NewLogWriter(&Logger, zerolog.InfoLevel)
+	// redirect stdout to the provided writer and stderr to the default logger
+	if stdout != nil {
+		cmd.Stdout = stdout
 	}
 	cmd.Stderr = NewLogWriter(&Logger, zerolog.ErrorLevel)
 
@@ -49,7 +221,35 @@ func ExecuteCmd(env []string, log bool, command string, args ...string) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
-	return cmd.Wait()
+	return wait(ctx, cmd)
+}
+
+// wait waits for a started command to finish. When ctx is done, the command receives SIGINT first and is killed if it
+// does not terminate within KillGracePeriod. The error of ctx takes precedence over the error of the command.
+func wait(ctx context.Context, cmd *exec.Cmd) error {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		Logger.Warn().Msgf("Interrupting command '%s' (%s)", cmd.Path, ctx.Err().Error())
+		_ = cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-done:
+		case <-time.After(KillGracePeriod):
+			Logger.Warn().Msgf("Killing command '%s' after grace period of %s", cmd.Path, KillGracePeriod)
+			_ = cmd.Process.Kill()
+		}
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
 }
 
 // HandleCmd invokes a function and handles the resulting error, if any. An error is written to the general logger
@@ -83,159 +283,644 @@ func NewResticManagerWithContext(cmd string, env []string) *ResticManager {
 	return &ResticManager{cmd: cmd, env: env}
 }
 
-// Backup performs a backup of the provided backup path and stores it in a restic repository. It uses the environment
-// settings defined in lib.GetSupportedSecrets and lib.GetSupportedVariables.
-func (r *ResticManager) Backup(path string, init bool, host string) error {
+// context returns the context of the restic manager, which defaults to the background context.
+func (r *ResticManager) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a copy of the restic manager that interrupts invoked commands when ctx is done.
+func (r *ResticManager) WithContext(ctx context.Context) *ResticManager {
+	m := *r
+	m.ctx = ctx
+	return &m
+}
+
+// Backup performs a backup of the provided backup path and stores it in a restic repository. See BackupWithOptions
+// for more details.
+func (r *ResticManager) Backup(path string, init bool, host string) (*BackupSummary, error) {
+	return r.BackupWithOptions(BackupOptions{Paths: []string{path}, Init: init, Host: host})
+}
+
+// BackupWithOptions performs a backup of the provided backup paths and stores it in a restic repository. It uses the
+// environment settings defined in lib.GetSupportedSecrets and lib.GetSupportedVariables. The output of the stdin
+// command is backed up instead of the paths, if defined. The backup runs with the '--json' flag, its summary is logged
+// as structured fields and returned to the caller. The summary is nil if restic did not report one. Any hooks run
+// before and after the backup, see Hooks.Wrap. Containers are stopped after the pre-backup hooks and restarted before
+// the post-backup hooks, see DockerOptions.Wrap.
+func (r *ResticManager) BackupWithOptions(opts BackupOptions) (*BackupSummary, error) {
+	return opts.Hooks.Wrap(r.context(), os.Environ(), opts.Paths, func() (*BackupSummary, error) {
+		return opts.Docker.Wrap(r.context(), func() (*BackupSummary, error) {
+			return r.backup(opts)
+		})
+	})
+}
+
+// backup performs the actual backup operation of BackupWithOptions, excluding any hooks.
+func (r *ResticManager) backup(opts BackupOptions) (*BackupSummary, error) {
+	path := strings.Join(opts.Paths, "', '")
+	if opts.Stdin != nil {
+		path = opts.Stdin.path()
+	}
 	Logger.Info().Msgf("Starting backup operation of path '%s'", path)
 
 	// check if the repository is already initialized and do so if instructed
 	if err := r.Execute(false, "snapshots"); err != nil {
-		if init {
+		if opts.Init {
 			Logger.Info().Msg("Initializing repository for first use")
 			if err := r.Execute(true, "init"); err != nil {
-				return &ResticError{Err: "Could not init repository", Fatal: true}
+				return nil, &ResticError{Err: "Could not init repository", Fatal: true, Cause: err}
 			}
 		} else {
-			return &ResticError{Err: "Could not open repository", Fatal: true}
+			return nil, &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
 		}
 	}
 
 	// ensure the repository is unlocked
 	if err := r.Execute(false, "unlock"); err != nil {
-		return &ResticError{Err: "Could not unlock repository", Fatal: true}
+		return nil, &ResticError{Err: "Could not unlock repository", Fatal: true, Cause: err}
 	}
 
 	// execute the backup command
-	args := []string{path}
-	if host != "" {
-		args = append(args, "--host="+host)
+	args := append([]string{}, opts.Paths...)
+	if opts.Stdin != nil {
+		args = []string{"--stdin", "--stdin-filename=" + opts.Stdin.filename()}
 	}
-	if err := r.Execute(true, "backup", args...); err != nil {
-		return err
+	for _, exclude := range opts.Excludes {
+		args = append(args, "--exclude="+exclude)
+	}
+	args = append(args, opts.Flags...)
+	for _, tag := range opts.Tags {
+		args = append(args, "--tag="+tag)
+	}
+	if opts.Host != "" {
+		args = append(args, "--host="+opts.Host)
+	}
+	args = append(args, "--json")
+	output := &BackupWriter{}
+	var err error
+	if opts.Stdin != nil {
+		err = r.executeWithStdin(opts.Stdin, output, "backup", args...)
+	} else {
+		err = ExecuteCmdWithWriter(r.context(), r.env, output, r.cmd, append([]string{"backup"}, args...)...)
+	}
+	output.Flush()
+
+	// log the backup summary, restic reports a summary on partial failure too (exit code 3)
+	summary := output.Summary
+	if summary != nil {
+		Logger.Info().EmbedObject(summary).Msg("Backup summary")
+	} else if err == nil {
+		Logger.Warn().Msg("Could not parse backup summary")
+	}
+	if err != nil {
+		return summary, err
 	}
 
 	Logger.Info().Msgf("Finished backup operation of path '%s'", path)
-	return nil
+	return summary, nil
 }
 
-// Check tests the repository for errors and reports any errors it finds.
-func (r *ResticManager) Check() error {
+// subsetRotation defines the persisted position of a rotating data subset check.
+type subsetRotation struct {
+	Next  int `json:"next"`
+	Total int `json:"total"`
+}
+
+// Check tests the repository for errors and reports any errors it finds. Provided arguments, such as
+// '--read-data-subset', are relayed to the restic binary.
+func (r *ResticManager) Check(args ...string) error {
 	Logger.Info().Msg("Executing check")
 
 	// ensure the repository is unlocked
 	if err := r.Execute(false, "unlock"); err != nil {
-		return &ResticError{Err: "Could not open repository", Fatal: true}
+		return &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
 	}
 
-	// execute the snapshots command
-	if err := r.Execute(true, "check"); err != nil {
-		return &ResticError{Err: "Could not execute check", Fatal: true}
+	// execute the check command
+	if err := r.Execute(true, "check", args...); err != nil {
+		return &ResticError{Err: "Could not execute check", Fatal: true, Cause: err}
 	}
 
 	Logger.Info().Msgf("Finished executing check")
 	return nil
 }
 
+// CheckSubset tests the repository for errors and verifies the data of a subset of the pack files. The subset is
+// either a fixed restic subset (e.g. '5%', '1/7', or '500M'), or the number of subsets to rotate through (e.g. '7').
+// In the latter case, successive checks verify the subsets 1/7, 2/7, and so on, until the whole repository has been
+// read. The next subset is persisted in the state store under key, and only advances after a successful check. An
+// empty subset checks the repository structure only.
+func (r *ResticManager) CheckSubset(subset string, store *StateStore, key string) error {
+	total, err := strconv.Atoi(subset)
+	if err != nil || total < 1 {
+		if subset == "" {
+			return r.Check()
+		}
+		return r.Check("--read-data-subset=" + subset)
+	}
+
+	// restore the position of the rotation, restart if the number of subsets has changed
+	var state subsetRotation
+	if err := store.Load(key, &state); err != nil {
+		Logger.Warn().Err(err).Msg("Could not read check state, restarting rotation")
+	}
+	if state.Total != total || state.Next < 1 || state.Next > total {
+		state = subsetRotation{Next: 1, Total: total}
+	}
+
+	Logger.Info().Msgf("Verifying data subset %d/%d", state.Next, total)
+	if err := r.Check(fmt.Sprintf("--read-data-subset=%d/%d", state.Next, total)); err != nil {
+		return err
+	}
+
+	// advance the rotation to the next subset
+	state.Next = state.Next%total + 1
+	if err := store.Save(key, state); err != nil {
+		Logger.Error().Err(err).Msg("Could not write check state")
+	}
+	return nil
+}
+
+// Copy copies snapshots from a source repository to the repository of the manager, without rescanning the backup
+// source. The source repository is defined by RESTIC_FROM_REPOSITORY and RESTIC_FROM_PASSWORD (or their file-based
+// variants), or RESTIC_FROM_PASSWORD_COMMAND. The filter selects the snapshots to copy. The destination repository is
+// initialized with the chunker parameters of the source if init is set, which ensures the copied data deduplicates
+// well.
+func (r *ResticManager) Copy(filter SnapshotFilter, init bool) error {
+	Logger.Info().Msg("Starting copy operation")
+
+	// validate the source repository is defined
+	if r.getEnv("RESTIC_FROM_REPOSITORY") == "" {
+		return &ResticError{Err: "Either 'RESTIC_FROM_REPOSITORY' or 'RESTIC_FROM_REPOSITORY_FILE' needs to be set",
+			Fatal: true}
+	}
+	if r.getEnv("RESTIC_FROM_PASSWORD") == "" && r.getEnv("RESTIC_FROM_PASSWORD_COMMAND") == "" {
+		return &ResticError{Err: "Either 'RESTIC_FROM_PASSWORD' or 'RESTIC_FROM_PASSWORD_FILE' needs to be set",
+			Fatal: true}
+	}
+
+	// check if the destination repository is already initialized and do so if instructed
+	if err := r.Execute(false, "snapshots"); err != nil {
+		if init {
+			Logger.Info().Msg("Initializing repository for first use")
+			if err := r.Execute(true, "init", "--copy-chunker-params"); err != nil {
+				return &ResticError{Err: "Could not init repository", Fatal: true, Cause: err}
+			}
+		} else {
+			return &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
+		}
+	}
+
+	// ensure the destination repository is unlocked
+	if err := r.Execute(false, "unlock"); err != nil {
+		return &ResticError{Err: "Could not unlock repository", Fatal: true, Cause: err}
+	}
+
+	// execute the copy command
+	if err := r.Execute(true, "copy", filter.args()...); err != nil {
+		return &ResticError{Err: "Could not complete copy operation", Fatal: false, Cause: err}
+	}
+
+	Logger.Info().Msgf("Finished copy operation")
+	return nil
+}
+
 // Execute invokes an external binary with a specific subcommand. It stages any Docker secrets as environment variables
 // first. The output of the command (both stdout and stderr) is logged in real time. See executeCmd for more details.
 func (r *ResticManager) Execute(log bool, subCmd string, args ...string) error {
 	// initiate the restic command with current environment and secrets
 	resticArgs := []string{subCmd}
 	resticArgs = append(resticArgs, args...)
-	return ExecuteCmd(r.env, log, r.cmd, resticArgs...)
+	return ExecuteCmd(r.context(), r.env, log, r.cmd, resticArgs...)
+}
+
+// Output invokes an external binary with a specific subcommand and returns its standard output. Errors (stderr) are
+// logged in real time. See ExecuteCmdWithWriter for more details.
+func (r *ResticManager) Output(subCmd string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	resticArgs := []string{subCmd}
+	resticArgs = append(resticArgs, args...)
+	err := ExecuteCmdWithWriter(r.context(), r.env, &stdout, r.cmd, resticArgs...)
+	return stdout.Bytes(), err
+}
+
+// filename returns the name of the file that holds the output of the stdin command in the snapshot.
+func (s *StdinSource) filename() string {
+	if s.Filename == "" {
+		return "stdin"
+	}
+	return s.Filename
+}
+
+// path returns the path of the stdin file in the snapshot, restic stores the file in the root of the snapshot.
+func (s *StdinSource) path() string {
+	return "/" + s.filename()
 }
 
-// Forget executes the restic forget command. The '--prune' flag is added by default. Provided keep-* flags are relayed
-// to the restic binary. Any stale locks on the repository are removed first.
-func (r *ResticManager) Forget(args []string) error {
+// Validate returns an error if the stdin source has no command or an invalid file name.
+func (s *StdinSource) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if strings.TrimSpace(s.Command) == "" {
+		return errors.New("No stdin command provided")
+	}
+	if strings.Contains(s.Filename, "/") {
+		return fmt.Errorf("Invalid stdin filename '%s'", s.Filename)
+	}
+	return nil
+}
+
+// paths returns the paths of the backup job that is not part of a backup set. The paths are omitted if the job backs
+// up the output of a stdin command instead.
+func (o ScheduleOptions) paths() []string {
+	if o.Stdin != nil {
+		return nil
+	}
+	return o.Paths
+}
+
+// filter returns the filter that selects the snapshots of the backup job that is not part of a backup set.
+func (o ScheduleOptions) filter() SnapshotFilter {
+	if o.Stdin != nil {
+		return SnapshotFilter{Host: o.Host, Paths: []string{o.Stdin.path()}}
+	}
+	return SnapshotFilter{Host: o.Host, Paths: o.Paths}
+}
+
+// timeout returns the timeout of the job identified by tag. A timeout defined for the exact tag takes precedence,
+// followed by the most specific tag that matches the job of a backup set or repository profile (see tagScopes). It
+// returns the default timeout otherwise.
+func (o ScheduleOptions) timeout(tag string) time.Duration {
+	tags := []string{}
+	for t := range o.Timeouts {
+		tags = append(tags, t)
+	}
+	if t, ok := bestTag(tags, tag); ok {
+		return o.Timeouts[t]
+	}
+	return o.Timeout
+}
+
+// catchup returns the catch-up policy of the job identified by tag, similar to timeout.
+func (o ScheduleOptions) catchup(tag string) CatchupPolicy {
+	tags := []string{}
+	for t := range o.Catchups {
+		tags = append(tags, t)
+	}
+	if t, ok := bestTag(tags, tag); ok {
+		return o.Catchups[t]
+	}
+	return o.Catchup
+}
+
+// executeWithStdin invokes an external binary with a specific subcommand, streaming the output of the stdin command
+// into its standard input. The standard output of the binary is written to stdout. An error is returned if either the
+// binary or the stdin command fails, as the snapshot is incomplete in the latter case.
+func (r *ResticManager) executeWithStdin(src *StdinSource, stdout io.Writer, subCmd string, args ...string) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+
+	// start the stdin command writing to the pipe, release the write end of this process afterwards
+	Logger.Debug().Msgf("Executing stdin command: %s", src.Command)
+	source := exec.Command("/bin/sh", "-c", src.Command)
+	source.Env = os.Environ()
+	source.Stdout = pw
+	source.Stderr = NewLogWriter(&Logger, zerolog.ErrorLevel)
+	err = source.Start()
+	pw.Close()
+	if err != nil {
+		return fmt.Errorf("Could not start stdin command: %s", err.Error())
+	}
+
+	// run the binary reading from the pipe; closing the read end unblocks the stdin command if the binary fails early
+	resticArgs := append([]string{subCmd}, args...)
+	err = ExecuteCmdWithIO(r.context(), r.env, pr, stdout, r.cmd, resticArgs...)
+	pr.Close()
+	if srcErr := wait(r.context(), source); srcErr != nil && err == nil {
+		err = fmt.Errorf("Stdin command failed: %s", srcErr.Error())
+	}
+	return err
+}
+
+// Forget executes the restic forget command. The '--prune' flag is added if prune is set, otherwise unreferenced data
+// remains in the repository until Prune is invoked. Provided keep-* flags are relayed to the restic binary. Any stale
+// locks on the repository are removed first.
+func (r *ResticManager) Forget(args []string, prune bool) error {
 	Logger.Info().Msg("Starting forget operation")
 
 	// check if the repository is already initialized
 	if err := r.Execute(false, "snapshots"); err != nil {
-		return &ResticError{Err: "Could not open repository", Fatal: true}
+		return &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
 	}
 
 	// ensure the repository is unlocked
 	if err := r.Execute(false, "unlock"); err != nil {
-		return &ResticError{Err: "Could not unlock repository", Fatal: true}
+		return &ResticError{Err: "Could not unlock repository", Fatal: true, Cause: err}
 	}
 
 	// execute the forget command
-	args = append(args, "--prune") // add --prune flag by default
+	if prune {
+		args = append(args, "--prune")
+	}
 	if err := r.Execute(true, "forget", args...); err != nil {
-		return errors.New("Could not complete forget operation")
+		return &ResticError{Err: "Could not complete forget operation", Fatal: false, Cause: err}
 	}
 
 	Logger.Info().Msgf("Finished forget operation")
 	return nil
 }
 
-// Restore retrieves a specific restic snapshot and restores it at the specified path.
+// Prune removes unreferenced data from the repository, such as the data of snapshots removed by Forget. Provided
+// flags, such as '--max-unused' and '--max-repack-size', are relayed to the restic binary. Any stale locks on the
+// repository are removed first.
+func (r *ResticManager) Prune(args []string) error {
+	Logger.Info().Msg("Starting prune operation")
+
+	// check if the repository is already initialized
+	if err := r.Execute(false, "snapshots"); err != nil {
+		return &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
+	}
+
+	// ensure the repository is unlocked
+	if err := r.Execute(false, "unlock"); err != nil {
+		return &ResticError{Err: "Could not unlock repository", Fatal: true, Cause: err}
+	}
+
+	// execute the prune command
+	if err := r.Execute(true, "prune", args...); err != nil {
+		return &ResticError{Err: "Could not complete prune operation", Fatal: false, Cause: err}
+	}
+
+	Logger.Info().Msgf("Finished prune operation")
+	return nil
+}
+
+// getEnv returns the value of the environment variable identified by key, or an empty string if not set.
+func (r *ResticManager) getEnv(key string) string {
+	for _, e := range r.env {
+		if strings.HasPrefix(e, key+"=") {
+			return strings.TrimPrefix(e, key+"=")
+		}
+	}
+	return ""
+}
+
+// targets returns the restic managers of the repository profiles identified by names, including 'all' to select all
+// profiles sorted by name. It returns the current manager if names is empty.
+func (r *ResticManager) targets(repositories map[string]*ResticManager, names []string) ([]*ResticManager, error) {
+	if len(names) == 0 {
+		return []*ResticManager{r}, nil
+	}
+
+	available := make([]string, 0, len(repositories))
+	for name := range repositories {
+		available = append(available, name)
+	}
+	names, err := resolveProfiles(available, names)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []*ResticManager{}
+	for _, name := range names {
+		targets = append(targets, repositories[name])
+	}
+	return targets, nil
+}
+
+// WithRepository returns a copy of the restic manager that uses the provided repository location instead of the
+// repository defined by the environment. The current manager is returned if repository is empty.
+func (r *ResticManager) WithRepository(repository string) *ResticManager {
+	if repository == "" {
+		return r
+	}
+	env := []string{}
+	for _, e := range r.env {
+		if !strings.HasPrefix(e, "RESTIC_REPOSITORY=") && !strings.HasPrefix(e, "RESTIC_REPOSITORY_FILE=") {
+			env = append(env, e)
+		}
+	}
+	env = append(env, "RESTIC_REPOSITORY="+repository)
+	return &ResticManager{cmd: r.cmd, env: env, profile: r.profile, ctx: r.ctx}
+}
+
+// Profile returns the name of the repository profile targeted by the restic manager, or an empty string if the
+// manager targets the repository defined by the environment.
+func (r *ResticManager) Profile() string {
+	return r.profile
+}
+
+// Restore retrieves a specific restic snapshot and restores it at the specified path. See RestoreWithOptions for more
+// details.
 func (r *ResticManager) Restore(path string, snapshot string) error {
+	return r.RestoreWithOptions(RestoreOptions{Snapshot: snapshot, Target: path})
+}
+
+// RestoreWithOptions retrieves a specific restic snapshot and restores it at the target path. The snapshot filter is
+// relayed to restic, which uses it to select the snapshot identified as 'latest'. Only the files matching the include
+// patterns are restored, if any, excluding the files matching the exclude patterns.
+func (r *ResticManager) RestoreWithOptions(opts RestoreOptions) error {
+	snapshot := opts.Snapshot
+	if snapshot == "" {
+		snapshot = "latest"
+	}
 	Logger.Info().Msgf("Starting restore operation for snapshot '%s'", snapshot)
 
 	// check if the repository is already initialized, fail if not available
 	if err := r.Execute(false, "snapshots"); err != nil {
-		return &ResticError{Err: "Could not open repository", Fatal: true}
+		return &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
 	}
 
 	// ensure the repository is unlocked
 	if err := r.Execute(false, "unlock"); err != nil {
-		return &ResticError{Err: "Could not unlock repository", Fatal: true}
+		return &ResticError{Err: "Could not unlock repository", Fatal: true, Cause: err}
 	}
 
-	if err := r.Execute(true, "restore", snapshot, "--target="+path); err != nil {
-		return &ResticError{Err: fmt.Sprintf("Could not restore snapshot '%s'", snapshot), Fatal: true}
+	args := append([]string{snapshot, "--target=" + opts.Target}, opts.Filter.args()...)
+	for _, include := range opts.Includes {
+		args = append(args, "--include="+include)
+	}
+	for _, exclude := range opts.Excludes {
+		args = append(args, "--exclude="+exclude)
+	}
+	if opts.Verify {
+		args = append(args, "--verify")
+	}
+	if err := r.Execute(true, "restore", args...); err != nil {
+		return &ResticError{Err: fmt.Sprintf("Could not restore snapshot '%s'", snapshot), Fatal: true, Cause: err}
 	}
 
 	Logger.Info().Msgf("Finished restore operation for snapshot '%s'", snapshot)
 	return nil
 }
 
-// Schedule starts the cron job following the provided BackupCron. If needed, the repository is initialized first. The
-// cron job runs indefinitely, unless interrupted (e.g. pressing Ctrl-C or sending SIGINT).
-func (r *ResticManager) Schedule(backupCron string, forgetCron, path string, init bool, host string, sustain bool,
-	keepFlags []string) error {
+// Jobs returns the cron jobs defined by the provided options. The jobs of a backup set are tagged with the name of the
+// set, e.g. 'backup-<name>' and 'forget-<name>'. Jobs targeting a repository profile have the profile name as suffix,
+// e.g. 'backup@<profile>'.
+func (r *ResticManager) Jobs(opts ScheduleOptions) ([]Job, error) {
+	var jobs []Job
+	tag := func(prefix string, m *ResticManager) string {
+		if m.profile != "" {
+			return prefix + "@" + m.profile
+		}
+		return prefix
+	}
 
-	Logger.Info().Msg("Executing schedule command")
+	store := NewStateStore(opts.StateDir)
+	targets, err := r.targets(opts.Repositories, opts.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range targets {
+		m := m
+		if opts.BackupCron != "" {
+			var backup Job
+			backup.Tag = tag("backup", m)
+			backup.Spec = opts.BackupCron
+			backup.RunS = func(ctx context.Context) (*BackupSummary, error) {
+				m := m.WithContext(ctx)
+				summary, err := m.BackupWithOptions(BackupOptions{Paths: opts.paths(), Flags: opts.BackupFlags,
+					Init: opts.Init, Host: opts.Host, Hooks: opts.Hooks, Docker: opts.Docker, Stdin: opts.Stdin})
+				if err == nil && opts.Diff {
+					m.diffBackup(summary, opts.filter())
+				}
+				return summary, err
+			}
+			backup.Ping = opts.Pings[backup.Tag]
+			jobs = append(jobs, backup)
+		}
 
-	var jobs []Job
+		if opts.ForgetCron != "" {
+			var forget Job
+			forget.Tag = tag("forget", m)
+			forget.Spec = opts.ForgetCron
+			forget.RunE = func(ctx context.Context) error {
+				return m.WithContext(ctx).Forget(opts.KeepFlags, opts.PruneCron == "")
+			}
+			forget.Ping = opts.Pings[forget.Tag]
+			jobs = append(jobs, forget)
+		}
 
-	if backupCron != "" {
-		var backup Job
-		backup.Tag = "backup"
-		backup.Spec = backupCron
-		backup.RunE = func() error {
-			return r.Backup(path, init, host)
+		if opts.CopyCron != "" {
+			var cp Job
+			cp.Tag = tag("copy", m)
+			cp.Spec = opts.CopyCron
+			cp.RunE = func(ctx context.Context) error {
+				return m.WithContext(ctx).Copy(SnapshotFilter{}, opts.Init)
+			}
+			cp.Ping = opts.Pings[cp.Tag]
+			jobs = append(jobs, cp)
+		}
+
+		if opts.PruneCron != "" {
+			var prune Job
+			prune.Tag = tag("prune", m)
+			prune.Spec = opts.PruneCron
+			prune.RunE = func(ctx context.Context) error {
+				return m.WithContext(ctx).Prune(opts.PruneFlags)
+			}
+			prune.Ping = opts.Pings[prune.Tag]
+			jobs = append(jobs, prune)
+		}
+
+		if opts.CheckCron != "" {
+			var check Job
+			key := tag("check", m)
+			check.Tag = key
+			check.Spec = opts.CheckCron
+			check.RunE = func(ctx context.Context) error {
+				return m.WithContext(ctx).CheckSubset(opts.CheckSubset, store, key)
+			}
+			check.Ping = opts.Pings[check.Tag]
+			jobs = append(jobs, check)
+		}
+
+		if opts.RestoreTestCron != "" {
+			var test Job
+			test.Tag = tag("restore-test", m)
+			test.Spec = opts.RestoreTestCron
+			test.RunE = func(ctx context.Context) error {
+				_, err := m.WithContext(ctx).RestoreTest(opts.RestoreTest)
+				return err
+			}
+			test.Ping = opts.Pings[test.Tag]
+			jobs = append(jobs, test)
 		}
-		jobs = append(jobs, backup)
 	}
 
-	if forgetCron != "" {
-		var forget Job
-		forget.Tag = "forget"
-		forget.Spec = forgetCron
-		forget.RunE = func() error { return r.Forget(keepFlags) }
-		jobs = append(jobs, forget)
+	for _, set := range opts.Sets {
+		set := set
+		targets, err := r.targets(opts.Repositories, set.Profiles)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid backup set '%s': %s", set.Name, err.Error())
+		}
+
+		for _, m := range targets {
+			m := m.WithRepository(set.Repository)
+
+			var backup Job
+			backup.Tag = tag("backup-"+set.Name, m)
+			backup.Spec = set.Cron
+			backup.RunS = func(ctx context.Context) (*BackupSummary, error) {
+				m := m.WithContext(ctx)
+				summary, err := m.BackupWithOptions(set.BackupOptions(opts.Init))
+				if err == nil && opts.Diff {
+					m.diffBackup(summary, set.SnapshotFilter())
+				}
+				return summary, err
+			}
+			backup.Ping = opts.Pings[backup.Tag]
+			jobs = append(jobs, backup)
+
+			if set.Forget != "" {
+				var forget Job
+				forget.Tag = tag("forget-"+set.Name, m)
+				forget.Spec = set.Forget
+				forget.RunE = func(ctx context.Context) error {
+					return m.WithContext(ctx).Forget(set.ForgetArgs(), opts.PruneCron == "")
+				}
+				forget.Ping = opts.Pings[forget.Tag]
+				jobs = append(jobs, forget)
+			}
+		}
 	}
 
-	return RunCronJobs(jobs, !sustain)
+	for i := range jobs {
+		jobs[i].Timeout = opts.timeout(jobs[i].Tag)
+		jobs[i].Catchup = opts.catchup(jobs[i].Tag)
+	}
+	return jobs, nil
 }
 
-// Snapshots lists all snapshots stored in the repository.
-func (r *ResticManager) Snapshots() error {
-	Logger.Info().Msg("Listing snapshots")
+// Schedule starts the cron jobs defined by the provided options, see Jobs. If needed, the repository is initialized
+// first. The cron jobs run indefinitely, unless terminated (e.g. pressing Ctrl-C or sending SIGINT or SIGTERM). The
+// jobs are rebuilt by opts.Reload on SIGHUP, or when triggered by opts.Watch.
+func (r *ResticManager) Schedule(opts ScheduleOptions) error {
+	Logger.Info().Msg("Executing schedule command")
 
-	// ensure the repository is unlocked
-	if err := r.Execute(false, "unlock"); err != nil {
-		return &ResticError{Err: "Could not open repository", Fatal: true}
+	jobs, err := r.Jobs(opts)
+	if err != nil {
+		return err
 	}
 
-	// execute the snapshots command
-	if err := r.Execute(true, "snapshots"); err != nil {
-		return &ResticError{Err: "Could not list snapshots", Fatal: true}
+	cronOpts := CronOptions{
+		HaltOnError:   !opts.Sustained,
+		Listen:        opts.Listen,
+		StatusFile:    opts.StatusFile,
+		Notifications: opts.Notifications,
+		DrainPeriod:   opts.DrainPeriod,
+		Reload:        opts.Reload,
+		Watch:         opts.Watch,
 	}
-
-	Logger.Info().Msgf("Finished listing snapshots")
-	return nil
+	if opts.StateDir != "" {
+		cronOpts.History = NewHistoryStore(opts.StateDir)
+	}
+	return RunCronJobsWithOptions(jobs, cronOpts)
 }
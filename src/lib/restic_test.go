@@ -4,9 +4,13 @@
 package lib
 
 import (
+	"context"
+	"errors"
+	"os"
 	"path"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -71,7 +75,7 @@ func validateLogs(t *testing.T, test string, got []string, want []string) {
 
 // 	// test the cmd invocation
 // 	path := path.Join(SourcePath(), "testcmd.sh")
-// 	if err := ExecuteCmd(env, true, path, "arg1", "arg2", "arg3"); err != nil {
+// 	if err := ExecuteCmd(context.Background(), env, true, path, "arg1", "arg2", "arg3"); err != nil {
 // 		t.Errorf("ExecuteCmd returned an error: %s.", err.Error())
 // 		return
 // 	}
@@ -105,17 +109,99 @@ func TestBackup(t *testing.T) {
 	expected := []string{
 		"snapshots",
 		"unlock",
-		"backup ./backup --host=HOST",
+		"backup ./backup --host=HOST --json",
 	}
 
 	var buffer LogBuffer
 	r := prepareContext(&buffer)
-	if err := r.Backup("./backup", true, "HOST"); err != nil {
+	if _, err := r.Backup("./backup", true, "HOST"); err != nil {
 		t.Errorf("%s returned an error: %s.", test, err.Error())
 	}
 	validateLogs(t, test, buffer, expected)
 }
 
+func TestBackupWithOptions(t *testing.T) {
+	const test = "BackupWithOptions"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"backup /data/docs /data/photos --exclude=*.tmp --exclude-caches=true --tag=docs --host=HOST --json",
+	}
+
+	opts := BackupOptions{Paths: []string{"/data/docs", "/data/photos"}, Excludes: []string{"*.tmp"},
+		Flags: []string{"--exclude-caches=true"}, Tags: []string{"docs"}, Host: "HOST"}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if _, err := r.BackupWithOptions(opts); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
+func TestBackupStdin(t *testing.T) {
+	const test = "BackupStdin"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"backup --stdin --stdin-filename=dump.sql --tag=db --json",
+	}
+
+	opts := BackupOptions{Tags: []string{"db"}, Stdin: &StdinSource{Command: "echo dump", Filename: "dump.sql"}}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if _, err := r.BackupWithOptions(opts); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+
+	// the fake restic binary logs its standard input to stderr, which confirms the command output is piped
+	piped := false
+	for _, l := range buffer {
+		piped = piped || strings.HasSuffix(l, "STDIN dump")
+	}
+	if !piped {
+		t.Errorf("%s did not pipe the output of the stdin command to restic.", test)
+	}
+
+	// a failing stdin command fails the backup, as the snapshot is incomplete
+	opts.Stdin.Command = "exit 1"
+	if _, err := r.BackupWithOptions(opts); err == nil {
+		t.Errorf("%s did not return an error for a failing stdin command.", test)
+	}
+}
+
+func TestBackupPartialFailure(t *testing.T) {
+	var buffer LogBuffer
+	InitLoggerWithWriter(LogFormat(Default), &buffer, true)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	// the fake restic binary reports an error and a summary, and exits with code 3 (snapshot created, files missing)
+	script := `#!/bin/sh
+[ "$1" = "backup" ] || exit 0
+echo '{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/data/secret"}'
+echo '{"message_type":"summary","files_new":2,"snapshot_id":"1a2b3c4d"}'
+exit 3
+`
+	restic := path.Join(t.TempDir(), "restic")
+	if err := os.WriteFile(restic, []byte(script), 0755); err != nil {
+		t.Fatalf("Cannot write fake restic: %s", err.Error())
+	}
+
+	r := NewResticManagerWithContext(restic, nil)
+	summary, err := r.Backup("/data", false, "")
+	if err == nil {
+		t.Errorf("Backup did not return an error for exit code 3")
+	}
+	if summary == nil || summary.SnapshotID != "1a2b3c4d" || summary.FilesNew != 2 {
+		t.Errorf("Backup did not return the summary of a failed backup, got: %+v.", summary)
+	}
+	if !Contains(buffer, "ERROR  Error during archival of '/data/secret': permission denied") {
+		t.Errorf("Backup did not log the error reported by restic, got: %v.", buffer)
+	}
+}
+
 func TestCheck(t *testing.T) {
 	const test = "Check"
 	expected := []string{
@@ -131,6 +217,58 @@ func TestCheck(t *testing.T) {
 	validateLogs(t, test, buffer, expected)
 }
 
+func TestCheckSubset(t *testing.T) {
+	const test = "CheckSubset"
+	expected := []string{
+		"unlock",
+		"check --read-data-subset=1/3",
+		"unlock",
+		"check --read-data-subset=2/3",
+		"unlock",
+		"check --read-data-subset=3/3",
+		"unlock",
+		"check --read-data-subset=1/3",
+		"unlock",
+		"check --read-data-subset=5%",
+	}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	store := NewStateStore(t.TempDir())
+	for i := 0; i < 4; i++ {
+		// simulate a restart by creating a new store for each run
+		if err := r.CheckSubset("3", NewStateStore(store.dir), "check"); err != nil {
+			t.Errorf("%s returned an error: %s.", test, err.Error())
+		}
+	}
+	if err := r.CheckSubset("5%", store, "check"); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
+func TestCopy(t *testing.T) {
+	const test = "Copy"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"copy --host=HOST --tag=TAG",
+	}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if err := r.Copy(SnapshotFilter{}, false); err == nil {
+		t.Errorf("%s without source repository returned unexpected result, got: nil, want: error", test)
+	}
+
+	buffer = LogBuffer{}
+	r.env = append(r.env, "RESTIC_FROM_REPOSITORY=SOURCE", "RESTIC_FROM_PASSWORD=SOURCE_PASSWORD")
+	if err := r.Copy(SnapshotFilter{Host: "HOST", Tags: []string{"TAG"}}, false); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
 func TestForget(t *testing.T) {
 	const test = "Forget"
 	expected := []string{
@@ -143,7 +281,39 @@ func TestForget(t *testing.T) {
 
 	var buffer LogBuffer
 	r := prepareContext(&buffer)
-	if err := r.Forget(args); err != nil {
+	if err := r.Forget(args, true); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
+func TestForgetWithoutPrune(t *testing.T) {
+	const test = "ForgetWithoutPrune"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"forget --keep-daily=2",
+	}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if err := r.Forget([]string{"--keep-daily=2"}, false); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
+func TestPrune(t *testing.T) {
+	const test = "Prune"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"prune --max-unused=10% --max-repack-size=2G",
+	}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if err := r.Prune([]string{"--max-unused=10%", "--max-repack-size=2G"}); err != nil {
 		t.Errorf("%s returned an error: %s.", test, err.Error())
 	}
 	validateLogs(t, test, buffer, expected)
@@ -165,17 +335,78 @@ func TestRestore(t *testing.T) {
 	validateLogs(t, test, buffer, expected)
 }
 
+func TestRestoreWithOptions(t *testing.T) {
+	const test = "RestoreWithOptions"
+	expected := []string{
+		"snapshots",
+		"unlock",
+		"restore latest --target=./restore --host=HOST --tag=daily --path=/data --include=/data/docs/report.pdf " +
+			"--exclude=*.tmp --verify",
+	}
+
+	opts := RestoreOptions{
+		Target:   "./restore",
+		Filter:   SnapshotFilter{Host: "HOST", Tags: []string{"daily"}, Paths: []string{"/data"}},
+		Includes: []string{"/data/docs/report.pdf"},
+		Excludes: []string{"*.tmp"},
+		Verify:   true,
+	}
+
+	var buffer LogBuffer
+	r := prepareContext(&buffer)
+	if err := r.RestoreWithOptions(opts); err != nil {
+		t.Errorf("%s returned an error: %s.", test, err.Error())
+	}
+	validateLogs(t, test, buffer, expected)
+}
+
 func TestSnapshots(t *testing.T) {
 	const test = "Snapshots"
 	expected := []string{
 		"unlock",
-		"snapshots",
+		"snapshots --host=HOST --tag=TAG1,TAG2 --path=/data --json",
 	}
 
+	filter := SnapshotFilter{Host: "HOST", Tags: []string{"TAG1,TAG2"}, Paths: []string{"/data"}}
+
 	var buffer LogBuffer
 	r := prepareContext(&buffer)
-	if err := r.Snapshots(); err != nil {
+	if err := r.Snapshots(filter, OutputFormat(TableOutput)); err != nil {
 		t.Errorf("%s returned an error: %s.", test, err.Error())
 	}
 	validateLogs(t, test, buffer, expected)
 }
+
+func TestExecuteCmdInterrupt(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := ExecuteCmd(ctx, nil, false, "sleep", "10")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExecuteCmd did not return the context error, got: %v.", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("ExecuteCmd did not interrupt the command")
+	}
+}
+
+func TestScheduleTimeout(t *testing.T) {
+	timeouts := map[string]time.Duration{"backup": 2 * time.Hour, "backup-db": 3 * time.Hour, "check@b2": time.Minute}
+	opts := ScheduleOptions{Timeout: time.Hour, Timeouts: timeouts}
+	tests := map[string]time.Duration{
+		"backup":        2 * time.Hour,
+		"backup@b2":     2 * time.Hour,
+		"backup-db":     3 * time.Hour,
+		"backup-db@nas": 3 * time.Hour,
+		"check":         time.Hour,
+		"check@b2":      time.Minute,
+		"forget":        time.Hour,
+	}
+	for tag, want := range tests {
+		if got := opts.timeout(tag); got != want {
+			t.Errorf("timeout returned incorrect value for '%s', got: %s, want: %s.", tag, got, want)
+		}
+	}
+}
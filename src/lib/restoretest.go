@@ -0,0 +1,298 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// RestoreTestOptions defines the options of ResticManager.RestoreTest. Filter selects the latest snapshot to restore.
+// Sample defines the number of randomly selected files to restore, the entire snapshot is restored if zero. The
+// restored files are compared against the live source, unless Manifest defines the path of a checksum file in the
+// format of 'sha256sum' (a hex-encoded SHA-256 checksum and absolute path per line). Dir defines the directory in which
+// the temporary restore directory is created, it defaults to the system's temporary directory.
+type RestoreTestOptions struct {
+	Filter   SnapshotFilter
+	Sample   int
+	Manifest string
+	Dir      string
+}
+
+// RestoreTestReport defines the outcome of a restore test. Verified counts the files with a matching checksum.
+// Skipped counts the files that cannot be compared, as they were modified or removed since the snapshot was taken, or
+// are not listed in the manifest. Differences lists the files with a different checksum or that were not restored.
+type RestoreTestReport struct {
+	SnapshotID  string   `json:"snapshot_id"`
+	Verified    int      `json:"verified"`
+	Skipped     int      `json:"skipped"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// lsNode defines a node of a snapshot, as reported by 'restic ls --json'. Only the fields of interest are captured.
+type lsNode struct {
+	StructType string `json:"struct_type"`
+	Type       string `json:"type"`
+	Path       string `json:"path"`
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// checksum returns the hex-encoded SHA-256 checksum of the file at path.
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readManifest reads a checksum file in the format of 'sha256sum' and returns the checksums by path. A leading '*'
+// of a path (indicating binary mode) is ignored.
+func readManifest(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid manifest line: %s", line)
+		}
+		manifest[strings.TrimPrefix(strings.TrimSpace(fields[1]), "*")] = strings.ToLower(fields[0])
+	}
+	return manifest, scanner.Err()
+}
+
+// latestSnapshot returns the most recent snapshot matching the filter.
+func (r *ResticManager) latestSnapshot(filter SnapshotFilter) (*Snapshot, error) {
+	snapshots, err := r.ListSnapshots(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Snapshot
+	for i := range snapshots {
+		if latest == nil || snapshots[i].Time.After(latest.Time) {
+			latest = &snapshots[i]
+		}
+	}
+	if latest == nil {
+		return nil, &ResticError{Err: "No snapshot available to restore", Fatal: false}
+	}
+	return latest, nil
+}
+
+// sampleFiles returns up to n randomly selected files of the snapshot identified by id.
+func (r *ResticManager) sampleFiles(id string, n int) ([]string, error) {
+	output, err := r.Output("ls", id, "--json")
+	if err != nil {
+		return nil, &ResticError{Err: "Could not list files of snapshot", Fatal: false, Cause: err}
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var node lsNode
+		if json.Unmarshal(scanner.Bytes(), &node) != nil {
+			continue
+		}
+		if node.StructType == "node" && node.Type == "file" {
+			files = append(files, node.Path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rand.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files, nil
+}
+
+// escapePattern escapes the characters of path that restic interprets as a glob pattern, so that an include pattern
+// matches the file of path only.
+func escapePattern(path string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(path)
+}
+
+// verifyRestore compares the checksum of each file restored in root against the manifest, or against the live file at
+// its original path if the manifest is nil. Live files that were modified since the snapshot, as indicated by a
+// different modification time, are skipped. The expected files must be present in root. If the entire snapshot is
+// restored, every file listed in the manifest is expected, so that a restore silently dropping files does not pass.
+func verifyRestore(root string, manifest map[string]string, expected []string) (*RestoreTestReport, error) {
+	report := &RestoreTestReport{}
+	restored := map[string]bool{}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		original := "/" + filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+		restored[original] = true
+
+		got, err := checksum(path)
+		if err != nil {
+			return err
+		}
+
+		var want string
+		if manifest != nil {
+			want = manifest[original]
+		} else if live, err := os.Stat(original); err == nil {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if live.ModTime().Equal(info.ModTime()) {
+				if want, err = checksum(original); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch {
+		case want == "":
+			Logger.Debug().Msgf("Skipping verification of modified or unknown file '%s'", original)
+			report.Skipped++
+		case want != got:
+			report.Differences = append(report.Differences, original+": checksum differs")
+		default:
+			report.Verified++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range expected {
+		if !restored[path] {
+			report.Differences = append(report.Differences, path+": not restored")
+		}
+	}
+	return report, nil
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// MarshalZerologObject implements the zerolog.LogObjectMarshaler interface, which allows a report to be embedded as
+// structured fields in a log message.
+func (r *RestoreTestReport) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("snapshot_id", r.SnapshotID).
+		Int("verified", r.Verified).
+		Int("skipped", r.Skipped).
+		Int("differences", len(r.Differences))
+}
+
+// RestoreTest restores the latest snapshot matching the filter, or a random sample of its files, into a temporary
+// directory and verifies the checksums of the restored files. The temporary directory is removed afterwards. Each
+// difference is logged, RestoreTest returns an error if any differences are found or if no file could be verified.
+func (r *ResticManager) RestoreTest(opts RestoreTestOptions) (*RestoreTestReport, error) {
+	Logger.Info().Msg("Starting restore test")
+
+	var manifest map[string]string
+	if opts.Manifest != "" {
+		var err error
+		if manifest, err = readManifest(opts.Manifest); err != nil {
+			return nil, fmt.Errorf("Could not read manifest: %s", err.Error())
+		}
+	}
+
+	snapshot, err := r.latestSnapshot(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	restore := RestoreOptions{Snapshot: snapshot.ID}
+	var sample []string
+	if opts.Sample > 0 {
+		if sample, err = r.sampleFiles(snapshot.ID, opts.Sample); err != nil {
+			return nil, err
+		}
+		if len(sample) == 0 {
+			return nil, &ResticError{Err: "Snapshot has no files to restore", Fatal: false}
+		}
+		for _, file := range sample {
+			restore.Includes = append(restore.Includes, escapePattern(file))
+		}
+	}
+
+	dir, err := os.MkdirTemp(opts.Dir, "restore-test-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	restore.Target = dir
+	if err := r.RestoreWithOptions(restore); err != nil {
+		return nil, &ResticError{Err: fmt.Sprintf("Restore test could not restore snapshot '%s'", snapshot.ShortID),
+			Fatal: false, Cause: err}
+	}
+
+	expected := sample
+	if opts.Sample == 0 {
+		for path := range manifest {
+			expected = append(expected, path)
+		}
+		sort.Strings(expected)
+	}
+	report, err := verifyRestore(dir, manifest, expected)
+	if err != nil {
+		return nil, fmt.Errorf("Could not verify restored files: %s", err.Error())
+	}
+	report.SnapshotID = snapshot.ID
+
+	for _, d := range report.Differences {
+		Logger.Error().Msgf("Restore test difference: %s", d)
+	}
+	Logger.Info().EmbedObject(report).Msg("Restore test report")
+
+	if len(report.Differences) > 0 {
+		return report, &ResticError{Err: fmt.Sprintf("Restore test found %d difference(s) in snapshot '%s'",
+			len(report.Differences), snapshot.ShortID), Fatal: false}
+	}
+	if report.Verified == 0 {
+		return report, errors.New("Restore test could not verify any file")
+	}
+	Logger.Info().Msg("Finished restore test")
+	return report, nil
+}
@@ -0,0 +1,174 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// prepareRestoreTest creates a live source directory, a copy of the source as captured by a snapshot, and a fake
+// restic binary that lists and restores the snapshot. The live file 'b' differs from the snapshot while retaining its
+// modification time, the live file 'c' was modified after the snapshot was taken.
+func prepareRestoreTest(t *testing.T) (*ResticManager, string) {
+	dir := t.TempDir()
+	src, snap := path.Join(dir, "src"), path.Join(dir, "snap")
+	mtime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range []string{src, snap} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("Cannot create directory: %s", err.Error())
+		}
+		for _, name := range []string{"a", "b", "c"} {
+			file := path.Join(d, name)
+			if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+				t.Fatalf("Cannot write file: %s", err.Error())
+			}
+			if err := os.Chtimes(file, mtime, mtime); err != nil {
+				t.Fatalf("Cannot change file time: %s", err.Error())
+			}
+		}
+	}
+	if err := os.WriteFile(path.Join(src, "b"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Cannot write file: %s", err.Error())
+	}
+	if err := os.Chtimes(path.Join(src, "b"), mtime, mtime); err != nil {
+		t.Fatalf("Cannot change file time: %s", err.Error())
+	}
+	if err := os.WriteFile(path.Join(src, "c"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("Cannot write file: %s", err.Error())
+	}
+
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+snapshots) echo '[{"id":"abc123","short_id":"abc","time":"2022-01-01T00:00:00Z","paths":["%[1]s"]}]' ;;
+ls) echo '{"struct_type":"snapshot"}'
+	for f in a b c; do echo "{\"struct_type\":\"node\",\"type\":\"file\",\"path\":\"%[1]s/$f\"}"; done ;;
+restore) for a in "$@"; do case "$a" in --target=*) target="${a#--target=}" ;; esac; done
+	mkdir -p "$target%[1]s" && cp -p %[2]s/* "$target%[1]s/" ;;
+esac
+`, src, snap)
+	restic := path.Join(dir, "restic")
+	if err := os.WriteFile(restic, []byte(script), 0755); err != nil {
+		t.Fatalf("Cannot write fake restic: %s", err.Error())
+	}
+	return NewResticManagerWithContext(restic, nil), src
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestRestoreTest(t *testing.T) {
+	r, src := prepareRestoreTest(t)
+	dir := t.TempDir()
+
+	report, err := r.RestoreTest(RestoreTestOptions{Sample: 2, Dir: dir})
+	if err == nil {
+		t.Errorf("RestoreTest did not report the difference of file 'b'")
+	}
+	if report == nil || report.Verified != 1 || report.Skipped != 1 || len(report.Differences) != 1 ||
+		!strings.HasPrefix(report.Differences[0], path.Join(src, "b")) {
+		t.Errorf("RestoreTest returned incorrect report, got: %+v.", report)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("RestoreTest did not remove the temporary directory")
+	}
+}
+
+func TestRestoreTestManifest(t *testing.T) {
+	r, src := prepareRestoreTest(t)
+	manifest := path.Join(t.TempDir(), "manifest.sha256")
+	content := "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  " + path.Join(src, "a") + "\n" +
+		"3E23E8160039594A33894F6564E1B1348BBD7A0088D42C4ACB73EEAED59C009D *" + path.Join(src, "b") + "\n"
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write manifest: %s", err.Error())
+	}
+
+	report, err := r.RestoreTest(RestoreTestOptions{Manifest: manifest, Dir: t.TempDir()})
+	if err != nil {
+		t.Errorf("RestoreTest returned an error: %s.", err.Error())
+	}
+	if report == nil || report.Verified != 2 || report.Skipped != 1 || len(report.Differences) != 0 {
+		t.Errorf("RestoreTest returned incorrect report, got: %+v.", report)
+	}
+}
+
+func TestRestoreTestManifestMissing(t *testing.T) {
+	r, src := prepareRestoreTest(t)
+	manifest := path.Join(t.TempDir(), "manifest.sha256")
+	content := "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  " + path.Join(src, "a") + "\n" +
+		"18ac3e7343f016890c510e93f935261169d9e3f565436429830faf0934f4f8e4  " + path.Join(src, "d") + "\n"
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write manifest: %s", err.Error())
+	}
+
+	// a file listed in the manifest that is missing from the restored snapshot is a difference
+	report, err := r.RestoreTest(RestoreTestOptions{Manifest: manifest, Dir: t.TempDir()})
+	if err == nil {
+		t.Errorf("RestoreTest did not report the missing file 'd'")
+	}
+	want := path.Join(src, "d") + ": not restored"
+	if report == nil || report.Verified != 1 || len(report.Differences) != 1 || report.Differences[0] != want {
+		t.Errorf("RestoreTest returned incorrect report, got: %+v, want difference: %s.", report, want)
+	}
+}
+
+func TestRestoreTestFailure(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+snapshots) echo '[{"id":"abc123","short_id":"abc","time":"2022-01-01T00:00:00Z","paths":["/data"]}]' ;;
+restore) echo "Fatal: repository is locked" >&2; exit 3 ;;
+esac
+`
+	restic := path.Join(dir, "restic")
+	if err := os.WriteFile(restic, []byte(script), 0755); err != nil {
+		t.Fatalf("Cannot write fake restic: %s", err.Error())
+	}
+	r := NewResticManagerWithContext(restic, nil)
+
+	// a failed restore fails the restore test only, it should not be reported as a fatal error of the scheduler
+	_, err := r.RestoreTest(RestoreTestOptions{Dir: dir})
+	var resticError *ResticError
+	if err == nil || !errors.As(err, &resticError) || resticError.Fatal {
+		t.Errorf("RestoreTest did not return a non-fatal error, got: %v.", err)
+	}
+	if code := ExitStatus(err); code != 3 {
+		t.Errorf("RestoreTest did not retain the exit status, got: %d, want: 3.", code)
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	tables := []struct {
+		path     string
+		expected string
+	}{
+		{"/data/file.txt", "/data/file.txt"},
+		{"/data/*.txt", `/data/\*.txt`},
+		{"/data/file?.txt", `/data/file\?.txt`},
+		{"/data/[a-z].txt", `/data/\[a-z].txt`},
+		{`/data/back\slash`, `/data/back\\slash`},
+	}
+
+	for _, table := range tables {
+		if escaped := escapePattern(table.path); escaped != table.expected {
+			t.Errorf("escapePattern('%s') = '%s', want '%s'", table.path, escaped, table.expected)
+		}
+		if ok, err := filepath.Match(escapePattern(table.path), table.path); err != nil || !ok {
+			t.Errorf("Escaped pattern of '%s' does not match the path itself", table.path)
+		}
+	}
+}
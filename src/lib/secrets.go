@@ -4,14 +4,12 @@
 package lib
 
 import (
-	"bufio"
 	"errors"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/imdario/mergo"
-	"github.com/olekukonko/tablewriter"
 )
 
 // EnvMap defines a function type to retrieve environment variables as key/value pairs in a map.
@@ -34,6 +32,8 @@ func GetSupportedSecrets() map[string]string {
 	return map[string]string{
 		"RESTIC_REPOSITORY_FILE":                "Name of file containing the repository location",
 		"RESTIC_PASSWORD_FILE":                  "Name of file containing the restic password",
+		"RESTIC_FROM_REPOSITORY_FILE":           "Name of file containing the source repository location of copy",
+		"RESTIC_FROM_PASSWORD_FILE":             "Name of file containing the password of the source repository",
 		"AWS_ACCESS_KEY_ID_FILE":                "Name of file containing the Amazon S3 access key ID",
 		"AWS_SECRET_ACCESS_KEY_FILE":            "Name of file containing the Amazon S3 secret access key",
 		"ST_USER_FILE":                          "Name of file containing the Username for keystone v1 authentication",
@@ -62,11 +62,33 @@ func GetSupportedVariables() map[string]string {
 	return map[string]string{
 		"RESTIC_LOGLEVEL":                  "Level of logging to use: panic, fatal, error, warn, info, debug, trace",
 		"RESTIC_TIMESTAMP":                 "Timestamp (RFC 3339) prefix for each log message (schedule defaults to true)",
-		"RESTIC_BACKUP_PATH":               "Local path to backup",
+		"RESTIC_BACKUP_PATH":               "Local paths to backup (separated by ':')",
 		"RESTIC_HOST":                      "Hostname to use in backups (defaults to $HOSTNAME)",
+		"RESTIC_FILES_FROM":                "Files with the paths to backup (space separated)",
+		"RESTIC_TAG":                       "Tags to add to new snapshots (space separated)",
+		"RESTIC_EXCLUDE":                   "Patterns to exclude from backups (space separated)",
+		"RESTIC_IEXCLUDE":                  "Patterns to exclude from backups, ignoring case (space separated)",
+		"RESTIC_EXCLUDE_FILE":              "Files with patterns to exclude from backups (space separated)",
+		"RESTIC_EXCLUDE_IF_PRESENT":        "Exclude directories containing any of the given files (space separated)",
+		"RESTIC_EXCLUDE_CACHES":            "Exclude directories containing a CACHEDIR.TAG file from backups",
+		"RESTIC_ONE_FILE_SYSTEM":           "Exclude other file systems from backups",
+		"RESTIC_STDIN_COMMAND":             "Shell command of which the output is backed up instead of the backup path",
+		"RESTIC_STDIN_FILENAME":            "File name of the stdin command output in the snapshot (defaults to stdin)",
+		"RESTIC_MAX_AGE":                   "Maximum age of the last successful backup validated by the health command",
+		"RESTIC_STATUS_FILE":               "Path of the status file written by the schedule command",
+		"RESTIC_STATE_DIR":                 "Directory to persist the state and job history of the schedule command",
+		"RESTIC_LISTEN":                    "Address of the HTTP status and control API of the schedule command",
+		"RESTIC_TIMEOUT":                   "Maximum duration of each job of the schedule command",
+		"RESTIC_GRACE_PERIOD":              "Time to wait for an interrupted command before it is killed",
+		"RESTIC_CATCHUP":                   "Policy for runs missed while the schedule command was not running",
+		"RESTIC_DRAIN_PERIOD":              "Time to wait for a running job when the schedule command stops",
+		"RESTIC_PROFILE":                   "Repository profiles to target (space separated), or 'all' for all profiles",
 		"RESTIC_REPOSITORY":                "Location of the repository",
 		"RESTIC_PASSWORD":                  "The actual password for the repository",
 		"RESTIC_PASSWORD_COMMAND":          "Command printing the password for the repository to stdout",
+		"RESTIC_FROM_REPOSITORY":           "Location of the source repository to copy snapshots from",
+		"RESTIC_FROM_PASSWORD":             "The actual password for the source repository",
+		"RESTIC_FROM_PASSWORD_COMMAND":     "Command printing the password for the source repository to stdout",
 		"RESTIC_KEY_HINT":                  "ID of key to try decrypting first, before other keys",
 		"RESTIC_CACHE_DIR":                 "Location of the cache directory",
 		"RESTIC_PROGRESS_FPS":              "Frames per second by which the progress bar is updated",
@@ -215,29 +237,8 @@ func (s *SecretsManager) List(listAll bool) error {
 	if len(overview) < 1 {
 		Logger.Info().Msg("No variables defined")
 	} else {
-		// render a simple aligned/padded ASCII table as string
-		tableString := &strings.Builder{}
-		table := tablewriter.NewWriter(tableString)
-		table.SetHeader([]string{"Variable", "Set", "Description"})
-		table.SetAutoWrapText(false)
-		table.SetAutoFormatHeaders(true)
-		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetCenterSeparator("")
-		table.SetColumnSeparator("")
-		table.SetRowSeparator("")
-		table.SetHeaderLine(false)
-		table.SetBorder(false)
-		table.SetTablePadding("  ") // pad with spaces
-		table.SetNoWhiteSpace(true)
-		table.AppendBulk(overview) // add Bulk Data
-		table.Render()
-
-		// log each line of the rendered table using a scanner
-		scanner := bufio.NewScanner(strings.NewReader(tableString.String()))
-		for scanner.Scan() {
-			Logger.Info().Msg(scanner.Text())
-		}
+		// render a simple aligned/padded ASCII table and log each line
+		LogLines(RenderTable([]string{"Variable", "Set", "Description"}, overview))
 	}
 
 	return nil
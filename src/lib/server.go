@@ -0,0 +1,143 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// shutdownTimeout defines the maximum time to wait for active HTTP connections to close.
+const shutdownTimeout = 5 * time.Second
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// handleJob processes requests for a single job identified by tag. It supports the following actions:
+//   - GET /jobs/<tag> returns the status of the job
+//   - POST /jobs/<tag>/run adds the job to the job channel immediately
+//   - POST /jobs/<tag>/pause skips the job when released by the cron scheduler
+//   - POST /jobs/<tag>/resume resumes a paused job
+func (s *scheduler) handleJob(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	tag, action := path, ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		tag, action = path[:i], path[i+1:]
+	}
+
+	job := s.find(tag)
+	if job == nil {
+		writeError(w, http.StatusNotFound, "Job '"+tag+"' not found")
+		return
+	}
+
+	if action == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.status(job))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	switch action {
+	case "run":
+		s.mu.Lock()
+		released := *job
+		s.mu.Unlock()
+		if !s.enqueue(released) {
+			writeError(w, http.StatusServiceUnavailable, "Job channel is full")
+			return
+		}
+		Logger.Info().Msgf("Triggered job '%s' via API", job.Tag)
+	case "pause", "resume":
+		s.mu.Lock()
+		job.state.paused = action == "pause"
+		s.mu.Unlock()
+		Logger.Info().Msgf("Job '%s' %sd via API", job.Tag, action)
+	default:
+		writeError(w, http.StatusNotFound, "Unknown action '"+action+"'")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.status(job))
+}
+
+// handleJobs returns the status of all scheduled jobs.
+func (s *scheduler) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.schedulerStatus().Jobs)
+}
+
+// handleStatus returns the status of the scheduler, including the current queue depth of the job channel.
+func (s *scheduler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.schedulerStatus())
+}
+
+// handler returns the HTTP handler of the status and control API. See handleStatus, handleJobs, handleJob, and
+// handleMetrics for the supported endpoints.
+func (s *scheduler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/jobs", s.handleJobs)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+// serve starts the HTTP status and control API in the background. Errors are written to the logger.
+func (s *scheduler) serve(addr string) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		Logger.Info().Msgf("Serving HTTP API at '%s'", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Logger.Error().Err(err).Msg("Could not serve HTTP API")
+		}
+	}()
+	return srv
+}
+
+// shutdownServer gracefully stops an HTTP server, waiting for active connections to close.
+func shutdownServer(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		Logger.Error().Err(err).Msg("Could not stop HTTP API")
+	}
+}
+
+// writeError writes an error message as JSON response.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// writeJSON writes a value as JSON response with the provided status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Logger.Error().Err(err).Msg("Could not write HTTP response")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+func prepareScheduler() *scheduler {
+	// suppress all log messages unless a (fatal) error occurred
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	var backup Job
+	backup.Tag = "backup"
+	backup.Spec = "@yearly"
+	backup.RunE = func(ctx context.Context) error { return errors.New("backup failed") }
+	return newScheduler([]Job{backup}, CronOptions{})
+}
+
+func request(t *testing.T, s *scheduler, method string, target string, wantCode int, v interface{}) {
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	if w.Code != wantCode {
+		t.Errorf("%s %s returned incorrect status code, got: %d, want: %d.", method, target, w.Code, wantCode)
+		return
+	}
+	if v != nil {
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Errorf("%s %s returned invalid JSON: %s.", method, target, err.Error())
+		}
+	}
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestHandleStatus(t *testing.T) {
+	s := prepareScheduler()
+	if _, err := s.process(*s.jobs[0]); err == nil {
+		t.Errorf("process returned unexpected result, got: nil, want: error")
+	}
+
+	var status SchedulerStatus
+	request(t, s, http.MethodGet, "/status", http.StatusOK, &status)
+	if status.QueueDepth != 0 || status.QueueCapacity != jobCapacity || len(status.Jobs) != 1 {
+		t.Errorf("GET /status returned incorrect status, got: %+v.", status)
+		return
+	}
+
+	job := status.Jobs[0]
+	if job.Tag != "backup" || job.Next == nil || job.LastResult != "error" || job.LastError != "backup failed" {
+		t.Errorf("GET /status returned incorrect job status, got: %+v.", job)
+	}
+
+	request(t, s, http.MethodPost, "/status", http.StatusMethodNotAllowed, nil)
+}
+
+func TestHandleJob(t *testing.T) {
+	s := prepareScheduler()
+
+	var job JobStatus
+	request(t, s, http.MethodGet, "/jobs/backup", http.StatusOK, &job)
+	if job.Tag != "backup" || job.Paused || job.LastResult != "" {
+		t.Errorf("GET /jobs/backup returned incorrect job status, got: %+v.", job)
+	}
+
+	request(t, s, http.MethodPost, "/jobs/backup/pause", http.StatusOK, &job)
+	if !job.Paused {
+		t.Errorf("POST /jobs/backup/pause did not pause the job")
+	}
+	request(t, s, http.MethodPost, "/jobs/backup/resume", http.StatusOK, &job)
+	if job.Paused {
+		t.Errorf("POST /jobs/backup/resume did not resume the job")
+	}
+
+	// fill the job channel to its capacity and confirm additional jobs are rejected
+	for i := 0; i < jobCapacity; i++ {
+		request(t, s, http.MethodPost, "/jobs/backup/run", http.StatusOK, nil)
+	}
+	request(t, s, http.MethodPost, "/jobs/backup/run", http.StatusServiceUnavailable, nil)
+
+	var jobs []JobStatus
+	request(t, s, http.MethodGet, "/jobs", http.StatusOK, &jobs)
+	if len(jobs) != 1 {
+		t.Errorf("GET /jobs returned incorrect number of jobs, got: %d, want: %d.", len(jobs), 1)
+	}
+
+	request(t, s, http.MethodGet, "/jobs/backup/run", http.StatusMethodNotAllowed, nil)
+	request(t, s, http.MethodPost, "/jobs/backup/unknown", http.StatusNotFound, nil)
+	request(t, s, http.MethodGet, "/jobs/unknown", http.StatusNotFound, nil)
+}
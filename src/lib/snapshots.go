@@ -0,0 +1,153 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// Snapshot defines a single snapshot stored in a restic repository, as reported by 'restic snapshots --json'.
+type Snapshot struct {
+	ID       string    `json:"id" yaml:"id"`
+	ShortID  string    `json:"short_id" yaml:"short_id"`
+	Time     time.Time `json:"time" yaml:"time"`
+	Hostname string    `json:"hostname" yaml:"hostname"`
+	Username string    `json:"username,omitempty" yaml:"username,omitempty"`
+	Tags     []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths    []string  `json:"paths" yaml:"paths"`
+}
+
+// SnapshotFilter defines the criteria to select snapshots. Empty fields are ignored. A snapshot matches the filter if
+// it has the specified host, at least one of the specified tags, and all of the specified paths. Multiple tags can be
+// combined into a single comma-separated value to require all of them.
+type SnapshotFilter struct {
+	Host  string
+	Tags  []string
+	Paths []string
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// args converts the filter to command-line arguments supported by restic.
+func (f SnapshotFilter) args() []string {
+	args := []string{}
+	if f.Host != "" {
+		args = append(args, "--host="+f.Host)
+	}
+	for _, tag := range f.Tags {
+		args = append(args, "--tag="+tag)
+	}
+	for _, path := range f.Paths {
+		args = append(args, "--path="+path)
+	}
+	return args
+}
+
+// snapshotRows converts a list of snapshots to table rows with the columns "ID", "Time", "Host", "Tags", and
+// "Paths".
+func snapshotRows(snapshots []Snapshot) [][]string {
+	rows := [][]string{}
+	for _, s := range snapshots {
+		rows = append(rows, []string{
+			s.ShortID,
+			s.Time.Local().Format("2006-01-02 15:04:05"),
+			s.Hostname,
+			strings.Join(s.Tags, ","),
+			strings.Join(s.Paths, ","),
+		})
+	}
+	return rows
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// ParseSnapshots converts the output of 'restic snapshots --json' into a list of snapshots. Restic prints the
+// snapshots as a single JSON array. Lines that cannot be parsed are written to the debug logger and are skipped.
+func ParseSnapshots(output []byte) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // allow for large repositories
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") {
+			if line != "" {
+				Logger.Debug().Msgf("Skipping snapshot output: %s", line)
+			}
+			continue
+		}
+
+		var s []Snapshot
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// ListSnapshots retrieves all snapshots stored in the repository that match the provided filter. Any stale locks on
+// the repository are removed first.
+func (r *ResticManager) ListSnapshots(filter SnapshotFilter) ([]Snapshot, error) {
+	// ensure the repository is unlocked
+	if err := r.Execute(false, "unlock"); err != nil {
+		return nil, &ResticError{Err: "Could not open repository", Fatal: true, Cause: err}
+	}
+
+	// execute the snapshots command
+	args := append(filter.args(), "--json")
+	output, err := r.Output("snapshots", args...)
+	if err != nil {
+		return nil, &ResticError{Err: "Could not list snapshots", Fatal: true, Cause: err}
+	}
+
+	snapshots, err := ParseSnapshots(output)
+	if err != nil {
+		return nil, &ResticError{Err: "Could not parse snapshots", Fatal: true, Cause: err}
+	}
+	return snapshots, nil
+}
+
+// Snapshots displays all snapshots stored in the repository that match the provided filter. The snapshots are
+// rendered as a table, as JSON, or as YAML.
+func (r *ResticManager) Snapshots(filter SnapshotFilter, format OutputFormat) error {
+	// log progress at debug level only, to keep JSON and YAML output parsable
+	Logger.Debug().Msg("Listing snapshots")
+
+	snapshots, err := r.ListSnapshots(filter)
+	if err != nil {
+		return err
+	}
+
+	// render output using logger
+	if len(snapshots) < 1 && format == OutputFormat(TableOutput) {
+		Logger.Info().Msg("No snapshots found")
+	} else {
+		header := []string{"ID", "Time", "Host", "Tags", "Paths"}
+		output, err := Render(format, header, snapshotRows(snapshots), snapshots)
+		if err != nil {
+			return &ResticError{Err: "Could not render snapshots", Fatal: true}
+		}
+		LogLines(output)
+	}
+
+	Logger.Debug().Msg("Finished listing snapshots")
+	return nil
+}
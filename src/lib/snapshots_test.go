@@ -0,0 +1,51 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+//======================================================================================================================
+// Constants and variables
+//======================================================================================================================
+
+const snapshotsOutput = `[{"time":"2022-01-02T03:04:05.000000000Z","tree":"abc","paths":["/data/backup"],` +
+	`"hostname":"host1","username":"restic","tags":["daily"],"id":"1a2b3c4d5e6f","short_id":"1a2b3c4d"},` +
+	`{"time":"2022-01-03T03:04:05.000000000Z","tree":"def","paths":["/data/backup","/data/db"],` +
+	`"hostname":"host2","id":"2b3c4d5e6f7a","short_id":"2b3c4d5e"}]
+`
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestParseSnapshots(t *testing.T) {
+	snapshots, err := ParseSnapshots([]byte("Skipped line\n" + snapshotsOutput))
+	if err != nil {
+		t.Errorf("ParseSnapshots returned an error: %s.", err.Error())
+		return
+	}
+	if len(snapshots) != 2 {
+		t.Errorf("ParseSnapshots returned incorrect number of snapshots, got: %d, want: %d.", len(snapshots), 2)
+		return
+	}
+
+	s := snapshots[0]
+	want := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	if s.ID != "1a2b3c4d5e6f" || s.ShortID != "1a2b3c4d" || s.Hostname != "host1" || !s.Time.Equal(want) {
+		t.Errorf("ParseSnapshots returned incorrect snapshot, got: %+v.", s)
+	}
+	if !Equal(s.Tags, []string{"daily"}) || !Equal(s.Paths, []string{"/data/backup"}) {
+		t.Errorf("ParseSnapshots returned incorrect tags or paths, got: %v, %v.", s.Tags, s.Paths)
+	}
+	if !Equal(snapshots[1].Paths, []string{"/data/backup", "/data/db"}) {
+		t.Errorf("ParseSnapshots returned incorrect paths, got: %v.", snapshots[1].Paths)
+	}
+
+	if _, err := ParseSnapshots([]byte("[invalid")); err == nil {
+		t.Errorf("ParseSnapshots returned unexpected result, got: nil, want: error")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// DefaultStateDir defines the default directory to persist state across restarts. The directory is exposed as volume
+// by the Docker image.
+const DefaultStateDir = "/data/state"
+
+// unsafeStateChars matches the characters that are replaced in the file name of a state document.
+var unsafeStateChars = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+
+// StateStore persists small JSON documents in a state directory, so that they survive restarts of the process or
+// container. Each document is identified by a name, which is converted to a safe file name.
+type StateStore struct {
+	dir string
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// path returns the file path of the document identified by name.
+func (s *StateStore) path(name string) string {
+	return filepath.Join(s.dir, unsafeStateChars.ReplaceAllString(name, "_")+".json")
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// NewStateStore creates a new state store persisting documents in dir. The directory is created when the first
+// document is saved.
+func NewStateStore(dir string) *StateStore {
+	return &StateStore{dir: dir}
+}
+
+// Load reads the document identified by name into v. It leaves v untouched and returns no error if the document
+// does not exist yet.
+func (s *StateStore) Load(name string, v interface{}) error {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("Cannot parse state '%s'", s.path(name))
+	}
+	return nil
+}
+
+// Save writes v as the document identified by name. The document is replaced atomically.
+func (s *StateStore) Save(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.path(name), data)
+}
@@ -0,0 +1,54 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestStateStore(t *testing.T) {
+	dir := path.Join(t.TempDir(), "state")
+	store := NewStateStore(dir)
+
+	// loading a missing document leaves the value untouched
+	state := subsetRotation{Next: 3, Total: 7}
+	if err := store.Load("check@nas", &state); err != nil {
+		t.Errorf("Load returned an error: %s.", err.Error())
+	}
+	if state.Next != 3 {
+		t.Errorf("Load changed missing state, got: %d, want: %d.", state.Next, 3)
+	}
+
+	// saving creates the directory and a safe file name
+	if err := store.Save("check/nas", subsetRotation{Next: 5, Total: 7}); err != nil {
+		t.Errorf("Save returned an error: %s.", err.Error())
+		return
+	}
+	if _, err := os.Stat(path.Join(dir, "check_nas.json")); err != nil {
+		t.Errorf("Save did not create the expected file: %s.", err.Error())
+	}
+
+	var got subsetRotation
+	if err := store.Load("check/nas", &got); err != nil {
+		t.Errorf("Load returned an error: %s.", err.Error())
+	}
+	if got.Next != 5 || got.Total != 7 {
+		t.Errorf("Load returned incorrect state, got: %+v, want: %+v.", got, subsetRotation{Next: 5, Total: 7})
+	}
+
+	// invalid documents return an error
+	if err := WriteFileAtomic(path.Join(dir, "invalid.json"), []byte("{")); err != nil {
+		t.Errorf("Could not write invalid state: %s.", err.Error())
+		return
+	}
+	if err := store.Load("invalid", &got); err == nil {
+		t.Errorf("Load returned unexpected result, got: nil, want: error")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Variables and user-defined types
+//======================================================================================================================
+
+// BackupSummary defines the outcome of a restic backup operation, as reported by the final 'summary' message of the
+// command 'restic backup --json'. Diff optionally holds the differences with the previous snapshot.
+type BackupSummary struct {
+	SnapshotID          string        `json:"snapshot_id"`
+	FilesNew            int           `json:"files_new"`
+	FilesChanged        int           `json:"files_changed"`
+	FilesUnmodified     int           `json:"files_unmodified"`
+	DirsNew             int           `json:"dirs_new"`
+	DirsChanged         int           `json:"dirs_changed"`
+	DirsUnmodified      int           `json:"dirs_unmodified"`
+	DataAdded           uint64        `json:"data_added"`
+	TotalFilesProcessed int           `json:"total_files_processed"`
+	TotalBytesProcessed uint64        `json:"total_bytes_processed"`
+	Duration            time.Duration `json:"duration"`
+	Diff                *DiffSummary  `json:"diff,omitempty"`
+}
+
+// backupMessage defines the structure of a JSON-formatted message produced by 'restic backup --json'. The message type
+// is either 'status', 'verbose_status', 'error', or 'summary'. Only the fields of interest are captured.
+type backupMessage struct {
+	MessageType string `json:"message_type"`
+	Error       struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	During              string  `json:"during"`
+	Item                string  `json:"item"`
+	SnapshotID          string  `json:"snapshot_id"`
+	FilesNew            int     `json:"files_new"`
+	FilesChanged        int     `json:"files_changed"`
+	FilesUnmodified     int     `json:"files_unmodified"`
+	DirsNew             int     `json:"dirs_new"`
+	DirsChanged         int     `json:"dirs_changed"`
+	DirsUnmodified      int     `json:"dirs_unmodified"`
+	DataAdded           uint64  `json:"data_added"`
+	TotalFilesProcessed int     `json:"total_files_processed"`
+	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
+	TotalDuration       float64 `json:"total_duration"`
+}
+
+// BackupWriter parses the output of 'restic backup --json' as it is written, which avoids holding the output of long
+// running backups in memory. Error messages reported by restic are written to the logger as they arrive, as are lines
+// that cannot be parsed as JSON. Status messages are discarded. Summary holds the summary message, if any.
+type BackupWriter struct {
+	Summary *BackupSummary
+	partial []byte
+}
+
+//======================================================================================================================
+// Private Functions
+//======================================================================================================================
+
+// parse handles a single line of output.
+func (w *BackupWriter) parse(data []byte) {
+	line := strings.TrimSpace(string(data))
+	if line == "" {
+		return
+	}
+
+	// relay lines that are not formatted as JSON to the logger
+	var msg backupMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		Logger.Info().Msg(line)
+		return
+	}
+
+	switch msg.MessageType {
+	case "error":
+		Logger.Error().Msgf("Error during %s of '%s': %s", msg.During, msg.Item, msg.Error.Message)
+	case "summary":
+		w.Summary = &BackupSummary{
+			SnapshotID:          msg.SnapshotID,
+			FilesNew:            msg.FilesNew,
+			FilesChanged:        msg.FilesChanged,
+			FilesUnmodified:     msg.FilesUnmodified,
+			DirsNew:             msg.DirsNew,
+			DirsChanged:         msg.DirsChanged,
+			DirsUnmodified:      msg.DirsUnmodified,
+			DataAdded:           msg.DataAdded,
+			TotalFilesProcessed: msg.TotalFilesProcessed,
+			TotalBytesProcessed: msg.TotalBytesProcessed,
+			Duration:            time.Duration(msg.TotalDuration * float64(time.Second)),
+		}
+	}
+}
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+// MarshalZerologObject implements the zerolog.LogObjectMarshaler interface, which allows a summary to be embedded
+// as structured fields in a log message.
+func (s *BackupSummary) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("snapshot_id", s.SnapshotID).
+		Int("files_new", s.FilesNew).
+		Int("files_changed", s.FilesChanged).
+		Int("files_unmodified", s.FilesUnmodified).
+		Uint64("data_added", s.DataAdded).
+		Dur("duration", s.Duration)
+}
+
+// ParseBackupOutput scans the output of 'restic backup --json' line by line and returns the backup summary. Error
+// messages reported by restic are written to the logger, as are lines that cannot be parsed as JSON. Status messages
+// are discarded. ParseBackupOutput returns an error if the output does not contain a summary.
+func ParseBackupOutput(output []byte) (*BackupSummary, error) {
+	w := &BackupWriter{}
+	w.Write(output)
+	w.Flush()
+	if w.Summary == nil {
+		return nil, fmt.Errorf("Backup output does not contain a summary")
+	}
+	return w.Summary, nil
+}
+
+// Write implements the io.Writer interface for a BackupWriter. Complete lines are parsed immediately, a trailing
+// partial line is retained until the next write or Flush.
+func (w *BackupWriter) Write(p []byte) (n int, err error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.parse(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush parses the remaining partial line, if any.
+func (w *BackupWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.parse(w.partial)
+		w.partial = nil
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2022 Mark Dumay. All rights reserved.
+// Use of this source code is governed by The MIT License (MIT) that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//======================================================================================================================
+// Constants and variables
+//======================================================================================================================
+
+const backupOutput = `{"message_type":"status","percent_done":0.5,"total_files":10,"files_done":5}
+{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/data/secret"}
+not a JSON message
+{"message_type":"summary","files_new":2,"files_changed":3,"files_unmodified":5,"dirs_new":1,"dirs_changed":0,` +
+	`"dirs_unmodified":4,"data_blobs":3,"tree_blobs":2,"data_added":2048,"total_files_processed":10,` +
+	`"total_bytes_processed":8192,"total_duration":1.5,"snapshot_id":"1a2b3c4d"}
+`
+
+//======================================================================================================================
+// Public Functions
+//======================================================================================================================
+
+func TestParseBackupOutput(t *testing.T) {
+	var buffer LogBuffer
+	InitLoggerWithWriter(LogFormat(Default), &buffer, true)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	summary, err := ParseBackupOutput([]byte(backupOutput))
+	if err != nil {
+		t.Errorf("ParseBackupOutput returned an error: %s.", err.Error())
+		return
+	}
+
+	want := BackupSummary{
+		SnapshotID:          "1a2b3c4d",
+		FilesNew:            2,
+		FilesChanged:        3,
+		FilesUnmodified:     5,
+		DirsNew:             1,
+		DirsUnmodified:      4,
+		DataAdded:           2048,
+		TotalFilesProcessed: 10,
+		TotalBytesProcessed: 8192,
+		Duration:            1500 * time.Millisecond,
+	}
+	if *summary != want {
+		t.Errorf("ParseBackupOutput returned incorrect summary, got: %+v, want: %+v.", *summary, want)
+	}
+
+	// validate the error message and the non-JSON line are relayed to the logger
+	if len(buffer) != 2 {
+		t.Errorf("ParseBackupOutput returned incorrect number of log messages, got: %d, want: %d.", len(buffer), 2)
+	}
+
+	if _, err := ParseBackupOutput([]byte("no summary")); err == nil {
+		t.Errorf("ParseBackupOutput returned unexpected result, got: nil, want: error")
+	}
+}
+
+func TestBackupWriter(t *testing.T) {
+	var buffer LogBuffer
+	InitLoggerWithWriter(LogFormat(Default), &buffer, true)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	// the error message is logged as soon as its line is complete, before restic exits
+	w := &BackupWriter{}
+	output := []byte(backupOutput)
+	split := strings.Index(backupOutput, "not a JSON")
+	w.Write(output[:split-10])
+	w.Write(output[split-10 : split+3])
+	if len(buffer) != 1 {
+		t.Errorf("BackupWriter did not log the error message in time, got: %v.", buffer)
+	}
+	w.Write(output[split+3:])
+	w.Flush()
+
+	if w.Summary == nil || w.Summary.SnapshotID != "1a2b3c4d" {
+		t.Errorf("BackupWriter returned incorrect summary, got: %+v.", w.Summary)
+	}
+	if len(buffer) != 2 {
+		t.Errorf("BackupWriter returned incorrect number of log messages, got: %d, want: %d.", len(buffer), 2)
+	}
+}
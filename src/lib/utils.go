@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 )
 
@@ -100,6 +101,28 @@ func WriteLine(path string, line string) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to a file by writing to a temporary file in the same directory first, which is then
+// renamed to the final path. Readers either see the old or the new content, but never a partially written file.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 // SourcePath returns the assumed main directory of the repository.
 func SourcePath() string {
 	if currentWorkingDirectory, err := os.Getwd(); err == nil {
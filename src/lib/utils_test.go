@@ -125,6 +125,24 @@ func TestReadLine(t *testing.T) {
 	}
 }
 
+func TestWriteFileAtomic(t *testing.T) {
+	path := path.Join(t.TempDir(), "test")
+
+	if err := WriteFileAtomic(path, []byte(test1)); err != nil {
+		t.Errorf("WriteFileAtomic returned an error: %s", err.Error())
+	}
+	if err := WriteFileAtomic(path, []byte(test2)); err != nil {
+		t.Errorf("WriteFileAtomic returned an error: %s", err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("WriteFileAtomic returned an error: %s", err.Error())
+	} else if string(data) != test2 {
+		t.Errorf("WriteFileAtomic wrote incorrect file contents, got: %s, want: %s", string(data), test2)
+	}
+}
+
 func TestWriteLine(t *testing.T) {
 	path := path.Join(t.TempDir(), "test")
 
@@ -1,8 +1,10 @@
 #!/bin/sh
 
-# Display command-line arguments
+# Display command-line arguments, consume standard input when instructed
 while [ -n "$1" ]; do
 case "$1" in
+        --stdin ) echo "ARG $1"
+                  echo "STDIN $(cat)" >&2;;
         * ) echo "ARG $1"
     esac
     shift